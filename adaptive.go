@@ -0,0 +1,108 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Schedule returns the desired sample size after observing count
+// items.
+type Schedule func(count int) int
+
+// SqrtSchedule returns a Schedule proportional to the square root of
+// the number of observations.
+func SqrtSchedule(scale float64) Schedule {
+	return func(count int) int {
+		return int(math.Ceil(scale * math.Sqrt(float64(count))))
+	}
+}
+
+// LogSchedule returns a Schedule proportional to the logarithm of the
+// number of observations.
+func LogSchedule(scale float64) Schedule {
+	return func(count int) int {
+		return int(math.Ceil(scale * math.Log1p(float64(count))))
+	}
+}
+
+// AdaptiveVaropt is a Varopt sampler for streams of unknown size.  It
+// starts with a small capacity and doubles it whenever the schedule
+// calls for a larger sample, up to a maximum capacity.  The sample
+// remains unbiased across growth events.
+type AdaptiveVaropt[T any] struct {
+	sampler     Varopt[T]
+	maxCapacity int
+	schedule    Schedule
+}
+
+// NewAdaptive returns a new AdaptiveVaropt sampler with the given
+// initial and maximum capacity, growth schedule, and random number
+// generator.
+func NewAdaptive[T any](initial, maxCapacity int, schedule Schedule, rnd *rand.Rand) *AdaptiveVaropt[T] {
+	a := &AdaptiveVaropt[T]{
+		maxCapacity: maxCapacity,
+		schedule:    schedule,
+	}
+	a.sampler.Init(initial, rnd)
+	return a
+}
+
+// Add considers a new observation for the sample with given weight,
+// then grows the capacity if the schedule calls for it.  See
+// Varopt.Add().
+func (a *AdaptiveVaropt[T]) Add(item T, weight float64) (T, error) {
+	eject, err := a.sampler.Add(item, weight)
+	if err != nil {
+		return eject, err
+	}
+
+	want := a.schedule(a.sampler.TotalCount())
+	capacity := a.sampler.Capacity()
+	for capacity < want && capacity < a.maxCapacity {
+		capacity = min(2*capacity, a.maxCapacity)
+	}
+	return eject, a.sampler.Resize(capacity)
+}
+
+// Get returns the i'th sample and its adjusted weight.
+func (a *AdaptiveVaropt[T]) Get(i int) (T, float64) {
+	return a.sampler.Get(i)
+}
+
+// GetOriginalWeight returns the original input weight of the i'th
+// sample.
+func (a *AdaptiveVaropt[T]) GetOriginalWeight(i int) float64 {
+	return a.sampler.GetOriginalWeight(i)
+}
+
+// Capacity returns the current size of the reservoir.
+func (a *AdaptiveVaropt[T]) Capacity() int {
+	return a.sampler.Capacity()
+}
+
+// MaxCapacity returns the size the reservoir will not grow beyond.
+func (a *AdaptiveVaropt[T]) MaxCapacity() int {
+	return a.maxCapacity
+}
+
+// Size returns the current number of items in the sample.
+func (a *AdaptiveVaropt[T]) Size() int {
+	return a.sampler.Size()
+}
+
+// TotalWeight returns the sum of weights that were passed to Add().
+func (a *AdaptiveVaropt[T]) TotalWeight() float64 {
+	return a.sampler.TotalWeight()
+}
+
+// TotalCount returns the number of calls to Add().
+func (a *AdaptiveVaropt[T]) TotalCount() int {
+	return a.sampler.TotalCount()
+}
+
+// Tau returns the current large-weight threshold.
+func (a *AdaptiveVaropt[T]) Tau() float64 {
+	return a.sampler.Tau()
+}
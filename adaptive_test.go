@@ -0,0 +1,63 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptive(t *testing.T) {
+	const (
+		initial       = 10
+		maxCapacity   = 5000
+		totalPackets  = 1e6
+		scheduleScale = 2
+	)
+
+	colors := []string{"red", "green", "blue"}
+	schedule := varopt.SqrtSchedule(scheduleScale)
+
+	rnd := rand.New(rand.NewSource(32491))
+	sampler := varopt.NewAdaptive[packet](initial, maxCapacity, schedule, rnd)
+
+	sizeByColor := map[string]float64{}
+	growths := 0
+
+	for i := 0; i < totalPackets; i++ {
+		p := packet{
+			size:  1 + rnd.Intn(100000),
+			color: colors[rnd.Intn(len(colors))],
+		}
+		sizeByColor[p.color] += float64(p.size)
+
+		before := sampler.Capacity()
+		_, err := sampler.Add(p, float64(p.size))
+		require.NoError(t, err)
+
+		capacity := sampler.Capacity()
+		want := min(schedule(sampler.TotalCount()), maxCapacity)
+		require.GreaterOrEqual(t, capacity, want)
+		require.LessOrEqual(t, capacity, max(initial, 2*want))
+		if capacity != before {
+			growths++
+		}
+	}
+
+	require.Equal(t, 2*1000, schedule(totalPackets))
+	require.Equal(t, 2560, sampler.Capacity())
+	require.Equal(t, sampler.Capacity(), sampler.Size())
+	require.Less(t, 5, growths)
+
+	estSizeByColor := map[string]float64{}
+	for i := 0; i < sampler.Size(); i++ {
+		p, weight := sampler.Get(i)
+		estSizeByColor[p.color] += weight
+	}
+	for _, c := range colors {
+		require.InEpsilon(t, sizeByColor[c], estSizeByColor[c], epsilon)
+	}
+}
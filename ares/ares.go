@@ -0,0 +1,90 @@
+// Copyright 2019, LightStep Inc.
+
+package ares
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/lightstep/varopt"
+	"github.com/lightstep/varopt/internal"
+)
+
+// Sampler implements A-Res weighted reservoir sampling.  Each item is
+// given the key u^(1/weight), for u uniform in (0, 1), and the sample
+// consists of the items with the largest keys, held in a min-heap.
+// Keys are compared in the log domain, log(u)/weight, to avoid
+// underflow for small weights.
+type Sampler[T any] struct {
+	capacity int
+	heap     internal.SampleHeap[entry[T]]
+	rnd      *rand.Rand
+}
+
+type entry[T any] struct {
+	item   T
+	weight float64
+}
+
+// New returns an A-Res sampler with given capacity (i.e., reservoir
+// size) and random number generator.
+func New[T any](capacity int, rnd *rand.Rand) *Sampler[T] {
+	return &Sampler[T]{
+		capacity: capacity,
+		heap:     make(internal.SampleHeap[entry[T]], 0, capacity+1),
+		rnd:      rnd,
+	}
+}
+
+// Add considers a new observation for the sample with given weight.
+// An error will be returned if the weight is either negative, zero,
+// infinite or NaN; see varopt.ErrInvalidWeight.
+func (s *Sampler[T]) Add(item T, weight float64) error {
+	if !(weight > 0) || math.IsInf(weight, 1) {
+		return varopt.ErrInvalidWeight
+	}
+	key := math.Log(s.uniform()) / weight
+
+	if len(s.heap) == s.capacity {
+		if least, ok := s.heap.Peek(); !ok || key <= least.Weight {
+			return nil
+		}
+		s.heap.Pop()
+	}
+	s.heap.Push(internal.Vsample[entry[T]]{
+		Sample: entry[T]{
+			item:   item,
+			weight: weight,
+		},
+		Weight: key,
+	})
+	return nil
+}
+
+// uniform returns a random number in (0, 1).
+func (s *Sampler[T]) uniform() float64 {
+	for {
+		r := s.rnd.Float64()
+		if r != 0.0 {
+			return r
+		}
+	}
+}
+
+// Get returns the i'th sample and its original weight, in no
+// particular order.
+func (s *Sampler[T]) Get(i int) (T, float64) {
+	e := s.heap[i].Sample
+	return e.item, e.weight
+}
+
+// Size returns the number of items in the sample.  If the reservoir is
+// full, Size() equals Capacity().
+func (s *Sampler[T]) Size() int {
+	return len(s.heap)
+}
+
+// Capacity returns the size of the reservoir.
+func (s *Sampler[T]) Capacity() int {
+	return s.capacity
+}
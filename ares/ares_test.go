@@ -0,0 +1,79 @@
+// Copyright 2019, LightStep Inc.
+
+package ares_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/lightstep/varopt/ares"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInclusionOne(t *testing.T) {
+	// With capacity 1, A-Res selects each item with probability
+	// proportional to its weight.
+	const trials = 100000
+	weights := []float64{1, 2, 3, 4}
+	rnd := rand.New(rand.NewSource(17167))
+	counts := make([]int, len(weights))
+
+	for trial := 0; trial < trials; trial++ {
+		s := ares.New[int](1, rnd)
+		for i, w := range weights {
+			require.NoError(t, s.Add(i, w))
+		}
+		require.Equal(t, 1, s.Size())
+		item, w := s.Get(0)
+		require.Equal(t, weights[item], w)
+		counts[item]++
+	}
+
+	for i, w := range weights {
+		require.InEpsilon(t, w/10, float64(counts[i])/trials, 0.03)
+	}
+}
+
+func TestInclusionTwo(t *testing.T) {
+	// With capacity 2, item i is included if it is drawn first,
+	// or second after some j:
+	//
+	//	P(i) = w_i/W + Σ_{j≠i} w_j/W × w_i/(W-w_j)
+	const trials = 100000
+	weights := []float64{1, 2, 3, 4}
+	rnd := rand.New(rand.NewSource(17167))
+	counts := make([]int, len(weights))
+
+	for trial := 0; trial < trials; trial++ {
+		s := ares.New[int](2, rnd)
+		for i, w := range weights {
+			require.NoError(t, s.Add(i, w))
+		}
+		require.Equal(t, 2, s.Size())
+		for k := 0; k < s.Size(); k++ {
+			item, _ := s.Get(k)
+			counts[item]++
+		}
+	}
+
+	for i, wi := range weights {
+		p := wi / 10
+		for j, wj := range weights {
+			if j != i {
+				p += wj / 10 * wi / (10 - wj)
+			}
+		}
+		require.InEpsilon(t, p, float64(counts[i])/trials, 0.03)
+	}
+}
+
+func TestInvalidWeight(t *testing.T) {
+	s := ares.New[int](1, rand.New(rand.NewSource(17167)))
+
+	for _, w := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		require.Equal(t, varopt.ErrInvalidWeight, s.Add(1, w))
+	}
+	require.Equal(t, 0, s.Size())
+}
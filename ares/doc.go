@@ -0,0 +1,17 @@
+// Copyright 2019, LightStep Inc.
+
+/*
+Package ares implements weighted reservoir sampling without
+replacement using Algorithm A-Res from "Weighted random sampling with
+a reservoir" by Pavlos S. Efraimidis and Paul G. Spirakis (2006)
+https://en.wikipedia.org/wiki/Reservoir_sampling#Algorithm_A-Res
+
+A-Res selects items one at a time with probability proportional to
+weight among those not yet selected, which is simpler than VarOpt but
+statistically different: inclusion probabilities are not proportional
+to weight, and there is no adjusted weight that makes subset sums
+unbiased, so A-Res samples are not suited to estimating totals.  Use
+varopt.Varopt for subset-sum estimation and A-Res when the sampling
+design itself is what is wanted.
+*/
+package ares
@@ -0,0 +1,95 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// ByteBudgetVaropt is a VarOpt sampler bounded by the total size of
+// the retained items rather than their number, for samples of items
+// whose sizes vary widely (e.g., logs and traces).  Weights and sizes
+// are independent: after each observation, items are ejected by the
+// VarOpt procedure, one at a time, until the retained items fit the
+// budget.  Each ejection leaves a valid VarOpt sample, so estimates
+// remain unbiased.
+type ByteBudgetVaropt[T any] struct {
+	sampler  Varopt[T]
+	maxBytes int
+	bytes    int
+	size     func(T) int
+}
+
+// NewByteBudget returns a new ByteBudgetVaropt sampler that retains
+// at most maxBytes, as measured by size, using the given random
+// number generator.  Like New(), NewByteBudget panics if maxBytes is
+// not positive or the random number generator is nil.
+func NewByteBudget[T any](maxBytes int, size func(T) int, rnd *rand.Rand) *ByteBudgetVaropt[T] {
+	if err := validate(maxBytes, fromRand(rnd)); err != nil {
+		panic(fmt.Sprintf("varopt: %v (maxBytes %d)", err, maxBytes))
+	}
+	b := &ByteBudgetVaropt[T]{
+		maxBytes: maxBytes,
+		size:     size,
+	}
+	// The reservoir never fills; ejection is driven by the budget.
+	b.sampler.rnd = fromRand(rnd)
+	b.sampler.capacity = math.MaxInt
+	return b
+}
+
+// Add considers a new observation for the sample with given weight.
+// An error will be returned if the weight is either negative or NaN.
+func (b *ByteBudgetVaropt[T]) Add(item T, weight float64) error {
+	if _, err := b.sampler.Add(item, weight); err != nil {
+		return err
+	}
+	b.bytes += b.size(item)
+	for b.bytes > b.maxBytes {
+		b.bytes -= b.size(b.sampler.shrink())
+	}
+	return nil
+}
+
+// Get returns the i'th sample and its adjusted weight.
+func (b *ByteBudgetVaropt[T]) Get(i int) (T, float64) {
+	return b.sampler.Get(i)
+}
+
+// GetOriginalWeight returns the original input weight of the i'th
+// sample.
+func (b *ByteBudgetVaropt[T]) GetOriginalWeight(i int) float64 {
+	return b.sampler.GetOriginalWeight(i)
+}
+
+// Size returns the current number of items in the sample.
+func (b *ByteBudgetVaropt[T]) Size() int {
+	return b.sampler.Size()
+}
+
+// Bytes returns the total size of the items in the sample.
+func (b *ByteBudgetVaropt[T]) Bytes() int {
+	return b.bytes
+}
+
+// MaxBytes returns the budget for the total size of the sample.
+func (b *ByteBudgetVaropt[T]) MaxBytes() int {
+	return b.maxBytes
+}
+
+// TotalWeight returns the sum of weights that were passed to Add().
+func (b *ByteBudgetVaropt[T]) TotalWeight() float64 {
+	return b.sampler.TotalWeight()
+}
+
+// TotalCount returns the number of calls to Add().
+func (b *ByteBudgetVaropt[T]) TotalCount() int {
+	return b.sampler.TotalCount()
+}
+
+// Tau returns the current large-weight threshold.
+func (b *ByteBudgetVaropt[T]) Tau() float64 {
+	return b.sampler.Tau()
+}
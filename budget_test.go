@@ -0,0 +1,71 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/require"
+)
+
+type logRecord struct {
+	color string
+	body  []byte
+}
+
+func TestByteBudget(t *testing.T) {
+	const (
+		maxBytes   = 1e6
+		totalCount = 1e5
+	)
+
+	colors := []string{"red", "green", "blue"}
+	rnd := rand.New(rand.NewSource(32491))
+	sampler := varopt.NewByteBudget[logRecord](maxBytes, func(r logRecord) int {
+		return len(r.body)
+	}, rnd)
+
+	weightByColor := map[string]float64{}
+	for i := 0; i < totalCount; i++ {
+		r := logRecord{
+			color: colors[rnd.Intn(len(colors))],
+			body:  make([]byte, 1+rnd.Intn(1000)),
+		}
+		weight := rnd.ExpFloat64()
+		weightByColor[r.color] += weight
+
+		require.NoError(t, sampler.Add(r, weight))
+		require.LessOrEqual(t, sampler.Bytes(), int(maxBytes))
+	}
+
+	bytes := 0
+	estByColor := map[string]float64{}
+	for i := 0; i < sampler.Size(); i++ {
+		r, weight := sampler.Get(i)
+		bytes += len(r.body)
+		estByColor[r.color] += weight
+	}
+	require.Equal(t, sampler.Bytes(), bytes)
+	require.Less(t, 1000, sampler.Size())
+
+	for _, c := range colors {
+		require.InEpsilon(t, weightByColor[c], estByColor[c], epsilon)
+	}
+}
+
+func TestByteBudgetInvalid(t *testing.T) {
+	size := func(r logRecord) int { return len(r.body) }
+	rnd := rand.New(rand.NewSource(32491))
+
+	require.PanicsWithValue(t, "varopt: Zero or negative capacity (maxBytes 0)", func() {
+		varopt.NewByteBudget[logRecord](0, size, rnd)
+	})
+	require.PanicsWithValue(t, "varopt: Zero or negative capacity (maxBytes -1)", func() {
+		varopt.NewByteBudget[logRecord](-1, size, rnd)
+	})
+	require.PanicsWithValue(t, "varopt: Nil random number generator (maxBytes 100)", func() {
+		varopt.NewByteBudget[logRecord](100, size, nil)
+	})
+}
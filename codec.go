@@ -0,0 +1,55 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Encoded is one sample decoded by Decode(): the encoded item paired
+// with its adjusted and original weights.
+type Encoded struct {
+	Item           []byte
+	AdjustedWeight float64
+	OriginalWeight float64
+}
+
+var ErrInvalidEncoding = fmt.Errorf("Encoded sample is too short")
+
+// encodedHeader is the size of the two weights preceding each item.
+const encodedHeader = 16
+
+// Encode returns one byte slice per sample, in Get() order, holding
+// the adjusted and original weights followed by the item as encoded
+// by enc.  This is a codec-agnostic primitive on which callers can
+// build any transport; use Decode() to recover the entries.
+func (s *Varopt[T]) Encode(enc func(T) []byte) [][]byte {
+	out := make([][]byte, s.Size())
+	for i := range out {
+		item, adjusted := s.Get(i)
+		b := make([]byte, encodedHeader, encodedHeader+8)
+		binary.LittleEndian.PutUint64(b[0:8], math.Float64bits(adjusted))
+		binary.LittleEndian.PutUint64(b[8:16], math.Float64bits(s.GetOriginalWeight(i)))
+		out[i] = append(b, enc(item)...)
+	}
+	return out
+}
+
+// Decode parses the output of Encode().  The returned Item slices
+// alias data.
+func Decode(data [][]byte) ([]Encoded, error) {
+	out := make([]Encoded, len(data))
+	for i, b := range data {
+		if len(b) < encodedHeader {
+			return nil, ErrInvalidEncoding
+		}
+		out[i] = Encoded{
+			Item:           b[encodedHeader:],
+			AdjustedWeight: math.Float64frombits(binary.LittleEndian.Uint64(b[0:8])),
+			OriginalWeight: math.Float64frombits(binary.LittleEndian.Uint64(b[8:16])),
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	const capacity = 100
+	const insert = 10000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	for i := 0; i < insert; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+
+	data := v.Encode(func(i testInt) []byte {
+		return binary.LittleEndian.AppendUint64(nil, uint64(i))
+	})
+	require.Equal(t, capacity, len(data))
+
+	decoded, err := varopt.Decode(data)
+	require.NoError(t, err)
+
+	// Re-import into a fresh sampler using the adjusted weights.
+	v2 := varopt.New[testInt](capacity, rnd)
+	expectSum := 0.
+	for i, d := range decoded {
+		item, adjusted := v.Get(i)
+		require.Equal(t, item, testInt(binary.LittleEndian.Uint64(d.Item)))
+		require.Equal(t, adjusted, d.AdjustedWeight)
+		require.Equal(t, v.GetOriginalWeight(i), d.OriginalWeight)
+
+		expectSum += adjusted
+		_, err := v2.Add(testInt(binary.LittleEndian.Uint64(d.Item)), d.AdjustedWeight)
+		require.NoError(t, err)
+	}
+
+	sum := 0.
+	for i := 0; i < v2.Size(); i++ {
+		_, w := v2.Get(i)
+		sum += w
+	}
+	require.InEpsilon(t, expectSum, sum, 1e-9)
+
+	_, err = varopt.Decode([][]byte{{1, 2, 3}})
+	require.Equal(t, varopt.ErrInvalidEncoding, err)
+}
@@ -0,0 +1,88 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Concurrent is a Varopt sampler that is safe for use by multiple
+// goroutines.  Varopt itself is not: Add() reorganizes the sample, so
+// even reading it with Get() while another goroutine calls Add() is
+// unsafe.  Concurrent guards every operation with a read-write mutex
+// and offers Snapshot() and View() for reading the whole sample
+// consistently.
+type Concurrent[T any] struct {
+	lock    sync.RWMutex
+	sampler Varopt[T]
+}
+
+// NewConcurrent returns a new Concurrent sampler with given capacity
+// and random number generator.  The generator must not be used
+// elsewhere.
+func NewConcurrent[T any](capacity int, rnd *rand.Rand) *Concurrent[T] {
+	c := &Concurrent[T]{}
+	c.sampler.Init(capacity, rnd)
+	return c
+}
+
+// Add considers a new observation for the sample; see Varopt.Add().
+func (c *Concurrent[T]) Add(item T, weight float64) (T, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.sampler.Add(item, weight)
+}
+
+// Get returns the i'th sample and its adjusted weight.  Indexes are
+// only meaningful between calls to Add(); use Snapshot() to read the
+// whole sample while other goroutines are adding.
+func (c *Concurrent[T]) Get(i int) (T, float64) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.sampler.Get(i)
+}
+
+// Size returns the current number of items in the sample.
+func (c *Concurrent[T]) Size() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.sampler.Size()
+}
+
+// TotalWeight returns the sum of weights that were passed to Add().
+func (c *Concurrent[T]) TotalWeight() float64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.sampler.TotalWeight()
+}
+
+// TotalCount returns the number of calls to Add().
+func (c *Concurrent[T]) TotalCount() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.sampler.TotalCount()
+}
+
+// Snapshot returns a copy of the current samples and their adjusted
+// weights.
+func (c *Concurrent[T]) Snapshot() []Sampled[T] {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	snap := make([]Sampled[T], c.sampler.Size())
+	for i := range snap {
+		snap[i].Item, snap[i].Weight = c.sampler.Get(i)
+	}
+	return snap
+}
+
+// View calls fn for each sample, as Varopt.View() does, while holding
+// a read lock, so that the sample is consistent without being copied.
+// Calls to Add() wait until View returns.  Fn must not call methods
+// of c, and must not retain pointers into item past the call, since
+// the sample may change once the lock is released.
+func (c *Concurrent[T]) View(fn func(i int, item T, adjusted, original float64)) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	c.sampler.View(fn)
+}
@@ -0,0 +1,93 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrent(t *testing.T) {
+	const (
+		capacity = 100
+		writers  = 8
+		perWrite = 10000
+	)
+
+	c := varopt.NewConcurrent[testInt](capacity, rand.New(rand.NewSource(98887)))
+
+	var writeWG, readWG sync.WaitGroup
+	done := make(chan struct{})
+
+	readWG.Add(1)
+	go func() {
+		defer readWG.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			snap := c.Snapshot()
+			assert.LessOrEqual(t, len(snap), capacity)
+			for _, s := range snap {
+				assert.Less(t, 0., s.Weight)
+			}
+			n := 0
+			c.View(func(i int, _ testInt, adjusted, original float64) {
+				assert.Equal(t, n, i)
+				assert.LessOrEqual(t, original, adjusted)
+				n++
+			})
+			assert.LessOrEqual(t, n, capacity)
+		}
+	}()
+
+	sums := make([]float64, writers)
+	for w := 0; w < writers; w++ {
+		writeWG.Add(1)
+		go func(w int) {
+			defer writeWG.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < perWrite; i++ {
+				weight := rnd.ExpFloat64()
+				sums[w] += weight
+				_, err := c.Add(testInt(w*perWrite+i), weight)
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+
+	writeWG.Wait()
+	close(done)
+	readWG.Wait()
+
+	total := 0.
+	for _, s := range sums {
+		total += s
+	}
+
+	require.Equal(t, capacity, c.Size())
+	require.Equal(t, writers*perWrite, c.TotalCount())
+	require.InEpsilon(t, total, c.TotalWeight(), 1e-9)
+
+	est := 0.
+	for _, s := range c.Snapshot() {
+		est += s.Weight
+	}
+	require.InEpsilon(t, total, est, 1e-9)
+
+	viewed := 0.
+	c.View(func(_ int, _ testInt, adjusted, _ float64) {
+		viewed += adjusted
+	})
+	require.Equal(t, est, viewed)
+
+	_, w := c.Get(0)
+	require.Less(t, 0., w)
+}
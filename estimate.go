@@ -0,0 +1,189 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"math"
+	"sort"
+)
+
+// WeightGini returns the Gini coefficient of the adjusted sample
+// weights: 0 when all weights are equal, approaching 1 as a single
+// item comes to dominate.  It returns 0 for an empty sample.
+func (s *Varopt[T]) WeightGini() float64 {
+	n := s.Size()
+	if n == 0 {
+		return 0
+	}
+	weights := make([]float64, n)
+	for i := range weights {
+		_, weights[i] = s.Get(i)
+	}
+	sort.Float64s(weights)
+
+	sum := 0.0
+	ranked := 0.0
+	for i, w := range weights {
+		sum += w
+		ranked += float64(i+1) * w
+	}
+	return 2*ranked/(float64(n)*sum) - float64(n+1)/float64(n)
+}
+
+// EstimateSum returns an unbiased estimate of the sum of value over
+// the observations for which include returns true.  Each sampled item
+// contributes its value scaled by its adjusted weight divided by its
+// original weight, i.e., by its inverse inclusion probability.
+func (s *Varopt[T]) EstimateSum(include func(T) bool, value func(T) float64) float64 {
+	sum := 0.0
+	for i := 0; i < s.Size(); i++ {
+		w := s.GetWeighted(i)
+		if include(w.Item) {
+			sum += value(w.Item) * w.AdjustedWeight / w.OriginalWeight
+		}
+	}
+	return sum
+}
+
+// EstimateVariance returns a conservative estimate of the variance of
+// the Horvitz-Thompson estimate of the population sum of value,
+//
+//	Σ value(item) × adjusted / original
+//
+// taken over the sample.  Each item is included with probability
+// p = original / adjusted, which is 1 for large-weight items and
+// original / Tau() for the others.  The result is the sum of per-item
+// terms
+//
+//	value(item)² × (1 - p) / p²
+//
+// which estimates Σ Var(adjusted_i × value / original_i), ignoring
+// the covariances between items.  Because VarOpt adjusted weights sum
+// exactly to TotalWeight(), those covariances are never positive, so
+// the result is an upper bound.  It is close when value is unrelated
+// to weight, and loose when value tracks it: when value returns the
+// original weight the estimated sum is exact, yet each light item
+// contributes Tau() × (Tau() - original).  Large-weight items
+// contribute zero.  For a subset sum, let value return zero outside
+// the subset.
+func (s *Varopt[T]) EstimateVariance(value func(T) float64) float64 {
+	sum := 0.0
+	for i := 0; i < s.Size(); i++ {
+		sum += s.VarianceContribution(i, value)
+	}
+	return sum
+}
+
+// VarianceContribution returns the i'th sample's term in
+// EstimateVariance(), value(item)² × (1 - p) / p², which is zero for
+// large-weight items.  It estimates the variance of the item's own
+// contribution to the sum, without the (non-positive) covariance with
+// the other items, and is useful for comparing which items make an
+// estimate noisy.
+func (s *Varopt[T]) VarianceContribution(i int, value func(T) float64) float64 {
+	item, _ := s.Get(i)
+	p := s.InclusionProbability(i)
+	v := value(item)
+	return v * v * (1 - p) / (p * p)
+}
+
+// EffectiveSampleSize returns Kish's effective sample size of the
+// adjusted weights, (Σw)² / Σw².  It equals Size() when all adjusted
+// weights are equal and falls toward 1 as a few heavy items come to
+// dominate, indicating less statistical power than the capacity
+// suggests.  It returns 0 for an empty sample.
+func (s *Varopt[T]) EffectiveSampleSize() float64 {
+	sum := 0.0
+	sumSq := 0.0
+	for _, w := range s.All() {
+		sum += w
+		sumSq += w * w
+	}
+	if sumSq == 0 {
+		return 0
+	}
+	return sum * sum / sumSq
+}
+
+// WeightedMean returns the mean of value over the sample, weighted by
+// adjusted weight, which estimates the weighted mean over the
+// observations.  It returns NaN for an empty sample.
+func (s *Varopt[T]) WeightedMean(value func(T) float64) float64 {
+	sum := 0.0
+	weight := 0.0
+	for item, w := range s.All() {
+		sum += w * value(item)
+		weight += w
+	}
+	if weight == 0 {
+		return math.NaN()
+	}
+	return sum / weight
+}
+
+// WeightedQuantile returns the q'th quantile, 0 <= q <= 1, of value
+// over the sample, treating the adjusted weights as a distribution.
+// Sample values are sorted and each is placed at the midpoint of its
+// share of the cumulative weight; the result interpolates linearly
+// between neighbouring values and is clamped to the smallest and
+// largest.  It returns NaN for an empty sample or q outside [0, 1].
+func (s *Varopt[T]) WeightedQuantile(q float64, value func(T) float64) float64 {
+	if s.Size() == 0 || !(q >= 0 && q <= 1) {
+		return math.NaN()
+	}
+	points := make([]Sampled[float64], 0, s.Size())
+	total := 0.0
+	for item, w := range s.All() {
+		points = append(points, Sampled[float64]{Item: value(item), Weight: w})
+		total += w
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Item < points[j].Item
+	})
+
+	target := q * total
+	cum := 0.0
+	prevPos, prevVal := 0.0, points[0].Item
+	for i, p := range points {
+		pos := cum + p.Weight/2
+		cum += p.Weight
+		if target <= pos {
+			if i == 0 {
+				return p.Item
+			}
+			return prevVal + (p.Item-prevVal)*(target-prevPos)/(pos-prevPos)
+		}
+		prevPos, prevVal = pos, p.Item
+	}
+	return prevVal
+}
+
+// Bootstrap returns rounds values of statistic computed on weighted
+// bootstrap resamples, whose spread indicates the uncertainty of the
+// statistic.  Each resample draws Size() indices into the sample with
+// replacement, with probability proportional to adjusted weight,
+// using the sampler's random number generator.  Statistic receives
+// the drawn indices, for use with Get(), and the weight that each
+// draw represents: the total adjusted weight divided by the number of
+// draws, so that Σ weights[j] × value(Get(indices[j])) estimates a
+// population sum.  The slices are reused between rounds and must not
+// be retained.  Bootstrap returns nil if rounds is not positive or
+// the sample is empty.
+func (s *Varopt[T]) Bootstrap(rounds int, statistic func(indices []int, weights []float64) float64) []float64 {
+	n := s.Size()
+	if rounds <= 0 || n == 0 {
+		return nil
+	}
+	cum := s.cumulativeWeights()
+	indices := make([]int, n)
+	weights := make([]float64, n)
+	out := make([]float64, rounds)
+	for r := range out {
+		for j := range indices {
+			indices[j] = s.drawIndex(cum)
+			weights[j] = cum[n-1] / float64(n)
+		}
+		out[r] = statistic(indices, weights)
+	}
+	return out
+}
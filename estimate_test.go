@@ -0,0 +1,257 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightGini(t *testing.T) {
+	const capacity = 100
+	rnd := rand.New(rand.NewSource(98887))
+
+	v := varopt.New[testInt](capacity, rnd)
+	require.Equal(t, 0., v.WeightGini())
+
+	for i := 0; i < 10000; i++ {
+		v.Add(testInt(i), 1)
+	}
+	require.InDelta(t, 0, v.WeightGini(), 1e-9)
+
+	v.Reset()
+	v.Add(0, 1e9)
+	for i := 1; i < capacity; i++ {
+		v.Add(testInt(i), 1)
+	}
+	require.Less(t, 0.98, v.WeightGini())
+}
+
+func TestEstimateVariance(t *testing.T) {
+	const (
+		capacity = 100
+		popSize  = 10000
+		trials   = 500
+	)
+
+	rnd := rand.New(rand.NewSource(98887))
+	weights := make([]float64, popSize)
+	for i := range weights {
+		weights[i] = rnd.ExpFloat64()
+	}
+	value := func(i testInt) float64 {
+		return float64(i % 10)
+	}
+
+	var estimates []float64
+	meanVariance := 0.
+	for trial := 0; trial < trials; trial++ {
+		v := varopt.New[testInt](capacity, rnd)
+		for i, w := range weights {
+			v.Add(testInt(i), w)
+		}
+
+		estimates = append(estimates, v.EstimateSum(func(testInt) bool {
+			return true
+		}, value))
+		meanVariance += v.EstimateVariance(value) / trials
+	}
+
+	mean := 0.
+	for _, e := range estimates {
+		mean += e / trials
+	}
+	empirical := 0.
+	for _, e := range estimates {
+		empirical += (e - mean) * (e - mean) / (trials - 1)
+	}
+
+	require.InEpsilon(t, empirical, meanVariance, 0.15)
+
+	// Estimating the total weight has zero variance, since adjusted
+	// weights sum to TotalWeight(), but the estimate ignores the
+	// negative covariances and is only an upper bound.
+	v := varopt.New[testInt](capacity, rnd)
+	for i, w := range weights {
+		v.Add(testInt(i), w)
+	}
+	weight := func(i testInt) float64 {
+		return weights[i]
+	}
+	require.InEpsilon(t, v.TotalWeight(), v.EstimateSum(func(testInt) bool {
+		return true
+	}, weight), 1e-9)
+	require.Less(t, 0., v.EstimateVariance(weight))
+
+	// Large-weight items contribute no variance.
+	v = varopt.New[testInt](capacity, rnd)
+	for i := 0; i < capacity; i++ {
+		v.Add(testInt(i), 1)
+	}
+	require.Equal(t, 0., v.EstimateVariance(value))
+}
+
+func TestEstimateSum(t *testing.T) {
+	const totalPackets = 1e6
+	const sampleRatio = 0.01
+
+	colors := []string{"red", "green", "blue"}
+	protocols := []string{"http", "tcp", "udp"}
+
+	sizeByColor := map[string]int{}
+	countByProtocol := map[string]int{}
+
+	rnd := rand.New(rand.NewSource(32491))
+	sampler := varopt.New[packet](totalPackets*sampleRatio, rnd)
+
+	for i := 0; i < totalPackets; i++ {
+		packet := packet{
+			size:     1 + rnd.Intn(100000),
+			color:    colors[rnd.Intn(len(colors))],
+			protocol: protocols[rnd.Intn(len(protocols))],
+		}
+
+		sizeByColor[packet.color] += packet.size
+		countByProtocol[packet.protocol]++
+
+		sampler.Add(packet, float64(packet.size))
+	}
+
+	size := func(p packet) float64 { return float64(p.size) }
+	count := func(packet) float64 { return 1 }
+
+	for _, c := range colors {
+		est := sampler.EstimateSum(func(p packet) bool { return p.color == c }, size)
+		require.InEpsilon(t, float64(sizeByColor[c]), est, 0.02)
+	}
+	for _, p := range protocols {
+		est := sampler.EstimateSum(func(q packet) bool { return q.protocol == p }, count)
+		require.InEpsilon(t, float64(countByProtocol[p]), est, epsilon)
+	}
+}
+
+func TestEffectiveSampleSize(t *testing.T) {
+	const capacity = 100
+	rnd := rand.New(rand.NewSource(98887))
+
+	v := varopt.New[testInt](capacity, rnd)
+	require.Equal(t, 0., v.EffectiveSampleSize())
+
+	for i := 0; i < 10000; i++ {
+		v.Add(testInt(i), 1+0.01*rnd.Float64())
+	}
+	require.InEpsilon(t, capacity, v.EffectiveSampleSize(), 1e-3)
+
+	// One dominant item lands among the large weights.
+	v.Add(-1, 1e6)
+	item, w := v.Get(0)
+	require.Equal(t, testInt(-1), item)
+	require.Equal(t, 1e6, w)
+	require.Less(t, v.EffectiveSampleSize(), 1.1)
+}
+
+func TestWeightedQuantile(t *testing.T) {
+	value := func(x testInt) float64 { return float64(x) }
+
+	v := varopt.New[testInt](10, rand.New(rand.NewSource(98887)))
+	require.True(t, math.IsNaN(v.WeightedMean(value)))
+	require.True(t, math.IsNaN(v.WeightedQuantile(0.5, value)))
+
+	for i := 4; i >= 1; i-- {
+		v.Add(testInt(i), 1)
+	}
+	require.Equal(t, 2.5, v.WeightedMean(value))
+	require.Equal(t, 1., v.WeightedQuantile(0, value))
+	require.Equal(t, 1.5, v.WeightedQuantile(0.25, value))
+	require.Equal(t, 2.5, v.WeightedQuantile(0.5, value))
+	require.Equal(t, 4., v.WeightedQuantile(1, value))
+	require.True(t, math.IsNaN(v.WeightedQuantile(1.5, value)))
+}
+
+func TestWeightedQuantileNormal(t *testing.T) {
+	const (
+		capacity = 1000
+		mean     = 10
+	)
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[float64](capacity, rnd)
+
+	for i := 0; i < 100000; i++ {
+		v.Add(mean+rnd.NormFloat64(), rnd.ExpFloat64())
+	}
+
+	identity := func(x float64) float64 { return x }
+	require.InDelta(t, mean, v.WeightedMean(identity), 0.1)
+	require.InDelta(t, mean, v.WeightedQuantile(0.5, identity), 0.1)
+	// The 84th percentile of a normal is about one standard
+	// deviation above the mean.
+	require.InDelta(t, mean+1, v.WeightedQuantile(0.8413, identity), 0.1)
+}
+
+func TestVarianceContribution(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](100, rnd)
+	for i := 0; i < 10000; i++ {
+		w := rnd.ExpFloat64()
+		if i%100 == 0 {
+			w *= 1000
+		}
+		v.Add(testInt(i), w)
+	}
+	value := func(x testInt) float64 { return float64(x % 7) }
+
+	sum := 0.
+	for i := 0; i < v.Size(); i++ {
+		c := v.VarianceContribution(i, value)
+		require.LessOrEqual(t, 0., c)
+		if v.InclusionProbability(i) == 1 {
+			require.Equal(t, 0., c)
+		}
+		sum += c
+	}
+	require.Less(t, 0., sum)
+	require.InEpsilon(t, v.EstimateVariance(value), sum, 1e-12)
+}
+
+func TestBootstrap(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[float64](1000, rnd)
+	require.Nil(t, v.Bootstrap(10, nil))
+
+	for i := 0; i < 100000; i++ {
+		v.Add(rnd.NormFloat64(), 0.5+rnd.Float64())
+	}
+	require.Nil(t, v.Bootstrap(0, nil))
+
+	mean := func(indices []int, weights []float64) float64 {
+		sum, weight := 0., 0.
+		for j, i := range indices {
+			x, _ := v.Get(i)
+			sum += weights[j] * x
+			weight += weights[j]
+		}
+		return sum / weight
+	}
+	means := v.Bootstrap(1000, mean)
+	require.Equal(t, 1000, len(means))
+
+	m, sq := 0., 0.
+	for _, x := range means {
+		m += x / float64(len(means))
+	}
+	for _, x := range means {
+		sq += (x - m) * (x - m) / float64(len(means)-1)
+	}
+
+	// With a population mean of zero, the variance of the estimated
+	// sum divided by the squared total weight is the variance of the
+	// mean.
+	identity := func(x float64) float64 { return x }
+	expect := math.Sqrt(v.EstimateVariance(identity)) / v.TotalWeight()
+	require.InEpsilon(t, expect, math.Sqrt(sq), 0.1)
+	require.InDelta(t, v.WeightedMean(identity), m, 3*expect)
+}
@@ -0,0 +1,44 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"fmt"
+
+	"github.com/lightstep/varopt/internal"
+)
+
+// debugX returns the transitional X buffer.  Add() relies on X being
+// empty between calls.
+func (s *Varopt[T]) debugX() []internal.Vsample[T] {
+	return s.X
+}
+
+// DebugX exposes debugX() to the external tests.
+func DebugX[T any](s *Varopt[T]) []internal.Vsample[T] {
+	return s.debugX()
+}
+
+// SetTotalCount sets the observation count, for testing large counts
+// without making that many calls to Add().
+func SetTotalCount[T any](s *Varopt[T], count int64) {
+	s.totalCount = count
+}
+
+// CheckInvariants returns an error describing the first violated
+// invariant of the reservoir between calls to Add().
+func CheckInvariants[T any](s *Varopt[T]) error {
+	switch {
+	case len(s.X) != 0:
+		return fmt.Errorf("X holds %d items", len(s.X))
+	case len(s.L)+len(s.T) > s.capacity:
+		return fmt.Errorf("%d+%d items exceed capacity %d", len(s.L), len(s.T), s.capacity)
+	case s.maxHeavy > s.capacity+1:
+		return fmt.Errorf("L held %d items, capacity %d", s.maxHeavy, s.capacity)
+	case len(s.T) != 0 && s.tau == 0:
+		return fmt.Errorf("T holds %d items with zero threshold", len(s.T))
+	}
+	// L may hold items no heavier than tau after Resize() grows the
+	// reservoir or Rescale() reduces weights, so that is not checked.
+	return nil
+}
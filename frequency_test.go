@@ -135,8 +135,8 @@ func ExampleVaropt_GetOriginalWeight() {
 
 	// Output:
 	// Samples per second mean 166.67
-	// Samples per second standard deviation 13.75
+	// Samples per second standard deviation 13.76
 	// Mean absolute percentage error (red) = 25.16%
 	// Mean absolute percentage error (green) = 14.30%
-	// Mean absolute percentage error (blue) = 14.23%
+	// Mean absolute percentage error (blue) = 14.24%
 }
@@ -1,8 +1,11 @@
 module github.com/lightstep/varopt
 
-go 1.21
+go 1.23
 
-require github.com/stretchr/testify v1.8.4
+require (
+	github.com/stretchr/testify v1.8.4
+	google.golang.org/protobuf v1.36.12
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,122 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/lightstep/varopt/internal"
+)
+
+// RegisterGob registers the concrete types of examples with
+// encoding/gob, as gob.Register() does.  Samplers whose element type
+// is an interface can only be encoded by GobEncode() once every
+// concrete type they may hold has been registered.
+func RegisterGob(examples ...any) {
+	for _, e := range examples {
+		gob.Register(e)
+	}
+}
+
+// gobState is the encoded form of a sampler.
+type gobState[T any] struct {
+	Capacity    int
+	Tau         float64
+	TotalCount  int64
+	TotalWeight float64
+	TotalComp   float64
+	L, T        []internal.Vsample[T]
+	HasFirst    bool
+	HasLast     bool
+	First, Last internal.Vsample[T]
+	Sticky      []internal.Vsample[T]
+	Cursor      []byte
+}
+
+// GobEncode implements gob.GobEncoder, encoding the sample, threshold
+// and totals.  Configuration, such as the random number generator,
+// callbacks and the settings of SetKeepFirst(), SetStable() and the
+// like, is not encoded.  Interface element types must be registered;
+// see RegisterGob().
+func (s *Varopt[T]) GobEncode() ([]byte, error) {
+	return s.encode(nil)
+}
+
+// MarshalWithCursor is like GobEncode(), but also encodes cursor, an
+// opaque position the caller associates with the sample, such as the
+// offset in an input file of the next observation.  Saving the two
+// together lets an interrupted batch job resume from a consistent
+// state; see UnmarshalWithCursor().
+func (s *Varopt[T]) MarshalWithCursor(cursor []byte) ([]byte, error) {
+	return s.encode(cursor)
+}
+
+// encode implements GobEncode() and MarshalWithCursor().
+func (s *Varopt[T]) encode(cursor []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(gobState[T]{
+		Capacity:    s.capacity,
+		Tau:         s.tau,
+		TotalCount:  s.totalCount,
+		TotalWeight: s.totalWeight,
+		TotalComp:   s.totalComp,
+		L:           s.L,
+		T:           s.T,
+		HasFirst:    s.hasFirst,
+		HasLast:     s.hasLast,
+		First:       s.first,
+		Last:        s.last,
+		Sticky:      s.sticky,
+		Cursor:      cursor,
+	})
+	return buf.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder, replacing the sample, threshold
+// and totals with those encoded by GobEncode().  The sampler keeps its
+// configuration, including its random number generator, which must be
+// set (e.g., by Init()) before further calls to Add().  On error the
+// sampler is not modified.
+func (s *Varopt[T]) GobDecode(data []byte) error {
+	_, err := s.decode(data)
+	return err
+}
+
+// UnmarshalWithCursor is like GobDecode(), for data encoded by
+// MarshalWithCursor() or GobEncode(), and returns the cursor saved
+// with the sample, which is nil for GobEncode().  On error the
+// sampler is not modified.
+func (s *Varopt[T]) UnmarshalWithCursor(data []byte) ([]byte, error) {
+	return s.decode(data)
+}
+
+// decode implements GobDecode() and UnmarshalWithCursor().
+func (s *Varopt[T]) decode(data []byte) ([]byte, error) {
+	var state gobState[T]
+	if s.sealed {
+		return nil, ErrSealed
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return nil, err
+	}
+	if state.Capacity <= 0 || len(state.L)+len(state.T) > state.Capacity {
+		return nil, ErrInvalidCapacity
+	}
+	s.capacity = state.Capacity
+	s.tau = state.Tau
+	s.totalCount = state.TotalCount
+	s.totalWeight = state.TotalWeight
+	s.totalComp = state.TotalComp
+	s.L, s.T, s.X = s.L[:0], s.T[:0], s.X[:0]
+	s.presize(s.capacity)
+	s.L = append(s.L, state.L...)
+	s.T = append(s.T, state.T...)
+	s.maxHeavy = len(s.L)
+	s.hasFirst = state.HasFirst
+	s.hasLast = state.HasLast
+	s.first = state.First
+	s.last = state.Last
+	s.sticky = append(s.sticky[:0], state.Sticky...)
+	return state.Cursor, nil
+}
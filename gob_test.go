@@ -0,0 +1,114 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/require"
+)
+
+type span interface {
+	ID() int
+}
+
+type clientSpan struct {
+	Num  int
+	Peer string
+}
+
+type serverSpan struct {
+	Num    int
+	Status int
+}
+
+func (c clientSpan) ID() int { return c.Num }
+func (s serverSpan) ID() int { return s.Num }
+
+func TestGobRoundTrip(t *testing.T) {
+	varopt.RegisterGob(clientSpan{}, serverSpan{})
+
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[span](100, rnd)
+	v.SetKeepFirst(true)
+	for i := 0; i < 10000; i++ {
+		var s span = clientSpan{Num: i, Peer: "peer"}
+		if i%2 == 0 {
+			s = serverSpan{Num: i, Status: 200}
+		}
+		v.Add(s, rnd.ExpFloat64())
+	}
+	require.NoError(t, v.AddSticky(clientSpan{Num: -1}, 1))
+
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(v))
+
+	r := varopt.New[span](1, rand.New(rand.NewSource(98887)))
+	require.NoError(t, gob.NewDecoder(&buf).Decode(r))
+
+	eq := func(a, b span) bool { return a == b }
+	require.True(t, v.Equal(r, eq))
+	require.Equal(t, v.TotalWeight(), r.TotalWeight())
+	for i := 0; i < v.Size(); i++ {
+		a, aw := v.Get(i)
+		b, bw := r.Get(i)
+		require.Equal(t, a, b)
+		require.Equal(t, aw, bw)
+	}
+
+	// The restored sampler accepts further observations.
+	for i := 10000; i < 11000; i++ {
+		r.Add(clientSpan{Num: i}, rnd.ExpFloat64())
+	}
+	require.Equal(t, 100+2, r.Size())
+	require.Equal(t, v.TotalCount()+1000, r.TotalCount())
+}
+
+func TestGobInvalid(t *testing.T) {
+	r := varopt.New[testInt](10, rand.New(rand.NewSource(98887)))
+	r.Add(1, 1)
+	require.Error(t, r.GobDecode([]byte("garbage")))
+	require.Equal(t, 1, r.Size())
+}
+
+func TestMarshalWithCursor(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](100, rnd)
+	for i := 0; i < 5000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	cursor := []byte("offset=5000")
+
+	data, err := v.MarshalWithCursor(cursor)
+	require.NoError(t, err)
+
+	r := varopt.New[testInt](1, rand.New(rand.NewSource(98887)))
+	got, err := r.UnmarshalWithCursor(data)
+	require.NoError(t, err)
+	require.Equal(t, cursor, got)
+	require.True(t, v.Equal(r, func(a, b testInt) bool { return a == b }))
+	require.Equal(t, v.TotalCount(), r.TotalCount())
+	require.Equal(t, v.TotalWeight(), r.TotalWeight())
+
+	// GobDecode() ignores the cursor, and data from GobEncode() has
+	// none.
+	g := varopt.New[testInt](1, rand.New(rand.NewSource(98887)))
+	require.NoError(t, g.GobDecode(data))
+	require.True(t, v.Equal(g, func(a, b testInt) bool { return a == b }))
+
+	data, err = v.GobEncode()
+	require.NoError(t, err)
+	got, err = r.UnmarshalWithCursor(data)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	// On error, the sampler and cursor are untouched.
+	got, err = r.UnmarshalWithCursor([]byte("garbage"))
+	require.Error(t, err)
+	require.Nil(t, got)
+	require.Equal(t, v.Size(), r.Size())
+}
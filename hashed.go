@@ -0,0 +1,52 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import "math/rand"
+
+// NewHashed returns a new Varopt sampler with given capacity whose
+// random choices are derived entirely from the seed and a hash of
+// each item passed to Add().  The resulting sample is a pure function
+// of the input sequence and seed, so two samplers given the same
+// input produce identical samples on any machine.
+//
+// Each call to Add() re-seeds a SplitMix64 generator using the seed,
+// the item's hash, and the number of prior observations, so repeated
+// items still receive independent choices.  The VarOpt threshold
+// logic is unchanged; only the source of uniform variates differs.
+func NewHashed[T any](capacity int, seed uint64, hash func(T) uint64) *Varopt[T] {
+	src := &hashSource{}
+	v := New[T](capacity, rand.New(src))
+	v.hash = hash
+	v.hashSeed = seed
+	v.hashSrc = src
+	return v
+}
+
+// hashSource is a SplitMix64 rand.Source64 that is re-seeded before
+// every observation.
+type hashSource struct {
+	state uint64
+}
+
+var _ rand.Source64 = &hashSource{}
+
+func (h *hashSource) reset(seed, hash, count uint64) {
+	h.state = seed ^ (hash * 0x9e3779b97f4a7c15) ^ (count * 0xbf58476d1ce4e5b9)
+}
+
+func (h *hashSource) Seed(seed int64) {
+	h.state = uint64(seed)
+}
+
+func (h *hashSource) Uint64() uint64 {
+	h.state += 0x9e3779b97f4a7c15
+	z := h.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (h *hashSource) Int63() int64 {
+	return int64(h.Uint64() >> 1)
+}
@@ -0,0 +1,84 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/require"
+)
+
+func hashInt(i testInt) uint64 {
+	h := fnv.New64a()
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(i))
+	h.Write(b[:])
+	return h.Sum64()
+}
+
+// hashedSampleBytes encodes the sample in item order, since the
+// order of Get() is an implementation detail.
+func hashedSampleBytes(v *varopt.Varopt[testInt]) []byte {
+	idx := make([]int, v.Size())
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool {
+		a, aw := v.Get(idx[i])
+		b, bw := v.Get(idx[j])
+		return a < b || (a == b && aw < bw)
+	})
+	var out []byte
+	for _, i := range idx {
+		item, weight := v.Get(i)
+		out = binary.LittleEndian.AppendUint64(out, uint64(item))
+		out = binary.LittleEndian.AppendUint64(out, math.Float64bits(weight))
+	}
+	return out
+}
+
+func TestHashed(t *testing.T) {
+	const capacity = 100
+	const rounds = 10000
+	const seed = 1234567
+
+	// Simulate two machines: each has differently seeded global
+	// and local random state, which must not matter.
+	inputs := rand.New(rand.NewSource(98887))
+	items := make([]testInt, rounds)
+	weights := make([]float64, rounds)
+	for i := range items {
+		items[i] = testInt(inputs.Intn(1e6))
+		weights[i] = inputs.ExpFloat64()
+	}
+
+	v1 := varopt.NewHashed[testInt](capacity, seed, hashInt)
+	rand.New(rand.NewSource(1)).Float64()
+	v2 := varopt.NewHashed[testInt](capacity, seed, hashInt)
+	v3 := varopt.NewHashed[testInt](capacity, seed+1, hashInt)
+
+	for i := range items {
+		_, err := v1.Add(items[i], weights[i])
+		require.NoError(t, err)
+		_, err = v2.Add(items[i], weights[i])
+		require.NoError(t, err)
+		_, err = v3.Add(items[i], weights[i])
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, capacity, v1.Size())
+	require.Equal(t, hashedSampleBytes(v1), hashedSampleBytes(v2))
+	require.NotEqual(t, hashedSampleBytes(v1), hashedSampleBytes(v3))
+
+	// The sample is fixed by the input and seed alone, so the
+	// result is pinned here to catch platform-dependent drift.
+	h := fnv.New64a()
+	h.Write(hashedSampleBytes(v1))
+	require.Equal(t, uint64(0x799df1ffb1dfaa71), h.Sum64())
+}
@@ -11,10 +11,44 @@ type SampleHeap[T any] []Vsample[T]
 
 func (sh *SampleHeap[T]) Push(v Vsample[T]) {
 	l := append(*sh, v)
+	up(l, len(l)-1)
+	*sh = l
+}
+
+func (sh *SampleHeap[T]) Pop() Vsample[T] {
+	l := *sh
 	n := len(l) - 1
+	result := l[0]
+	l[0] = l[n]
+	l = l[:n]
+
+	down(l, 0, n)
+
+	*sh = l
+	return result
+}
+
+// Peek returns the minimum-weight element without removing it, and
+// false if the heap is empty.
+func (sh SampleHeap[T]) Peek() (Vsample[T], bool) {
+	if len(sh) == 0 {
+		return Vsample[T]{}, false
+	}
+	return sh[0], true
+}
 
-	// This copies the body of heap.up().
-	j := n
+// Init establishes the heap ordering of an arbitrarily ordered
+// SampleHeap.  The result is identical to Push()ing each element in
+// order, so that deferring heap construction does not change which
+// of several equal-weight items is popped first.
+func (sh SampleHeap[T]) Init() {
+	for j := 1; j < len(sh); j++ {
+		up(sh, j)
+	}
+}
+
+// This copies the body of heap.up().
+func up[T any](l SampleHeap[T], j int) {
 	for {
 		i := (j - 1) / 2 // parent
 		if i == j || l[j].Weight >= l[i].Weight {
@@ -23,19 +57,10 @@ func (sh *SampleHeap[T]) Push(v Vsample[T]) {
 		l[i], l[j] = l[j], l[i]
 		j = i
 	}
-
-	*sh = l
 }
 
-func (sh *SampleHeap[T]) Pop() Vsample[T] {
-	l := *sh
-	n := len(l) - 1
-	result := l[0]
-	l[0] = l[n]
-	l = l[:n]
-
-	// This copies the body of heap.down().
-	i := 0
+// This copies the body of heap.down().
+func down[T any](l SampleHeap[T], i, n int) {
 	for {
 		j1 := 2*i + 1
 		if j1 >= n || j1 < 0 { // j1 < 0 after int overflow
@@ -51,7 +76,4 @@ func (sh *SampleHeap[T]) Pop() Vsample[T] {
 		l[i], l[j] = l[j], l[i]
 		i = j
 	}
-
-	*sh = l
-	return result
 }
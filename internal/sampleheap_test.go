@@ -59,3 +59,96 @@ func TestLargeHeap(t *testing.T) {
 
 	require.Equal(t, 0, len(L))
 }
+
+func TestHeapInit(t *testing.T) {
+	var L internal.SampleHeap[float64]
+	var S simpleHeap
+
+	for i := 0; i < 1e5; i++ {
+		v := rand.NormFloat64()
+		L = append(L, internal.Vsample[float64]{
+			Sample: v,
+			Weight: v,
+		})
+		S = append(S, v)
+	}
+	var P internal.SampleHeap[float64]
+	for _, v := range L {
+		P.Push(v)
+	}
+	L.Init()
+	heap.Init(&S)
+
+	require.Equal(t, P, L)
+
+	for len(S) > 0 {
+		require.Equal(t, heap.Pop(&S), L.Pop().Weight)
+	}
+	require.Equal(t, 0, len(L))
+}
+
+// The benchmarks below compare SampleHeap with the equivalent
+// container/heap usage, which converts each element to and from an
+// interface{} in Push and Pop.
+
+func benchmarkHeapWeights(b *testing.B, size int) []float64 {
+	rnd := rand.New(rand.NewSource(3331))
+	weights := make([]float64, size)
+	for i := range weights {
+		weights[i] = rnd.ExpFloat64()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	return weights
+}
+
+func BenchmarkSampleHeap_1000000(b *testing.B) {
+	const size = 1000000
+	weights := benchmarkHeapWeights(b, size)
+	L := make(internal.SampleHeap[float64], 0, size)
+
+	for i := 0; i < b.N; i++ {
+		w := weights[i%size]
+		if len(L) == size {
+			L.Pop()
+		}
+		L.Push(internal.Vsample[float64]{
+			Sample: w,
+			Weight: w,
+		})
+	}
+}
+
+func BenchmarkContainerHeap_1000000(b *testing.B) {
+	const size = 1000000
+	weights := benchmarkHeapWeights(b, size)
+	S := make(simpleHeap, 0, size)
+
+	for i := 0; i < b.N; i++ {
+		w := weights[i%size]
+		if len(S) == size {
+			heap.Pop(&S)
+		}
+		heap.Push(&S, w)
+	}
+}
+
+func TestHeapPeek(t *testing.T) {
+	var L internal.SampleHeap[float64]
+
+	_, ok := L.Peek()
+	require.False(t, ok)
+
+	for _, w := range []float64{3, 1, 2} {
+		L.Push(internal.Vsample[float64]{Sample: w, Weight: w})
+	}
+	v, ok := L.Peek()
+	require.True(t, ok)
+	require.Equal(t, 1., v.Weight)
+	require.Equal(t, 3, len(L))
+
+	L.Pop()
+	v, ok = L.Peek()
+	require.True(t, ok)
+	require.Equal(t, 2., v.Weight)
+}
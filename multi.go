@@ -0,0 +1,140 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// MultiVaropt maintains a single VarOpt sample over items that carry
+// more than one weight dimension (e.g., byte size and request
+// count), supporting subset-sum estimates in each dimension.
+//
+// Items are admitted using the largest of their weights, each
+// normalized by the running mean weight of its dimension, so that a
+// dimension does not dominate the sample merely because of its scale.
+// The sample is variance-optimal for that combined weight; it is not
+// in general variance-optimal for any individual dimension, but items
+// that are large in any dimension relative to that dimension's mean
+// are favored.  Per-dimension estimates use the Horvitz-Thompson
+// estimator, scaling each dimension's weight by the inverse inclusion
+// probability of the item, and remain unbiased.
+type MultiVaropt[T any] struct {
+	dims    int
+	sampler Varopt[multiSample[T]]
+	totals  []float64
+}
+
+type multiSample[T any] struct {
+	item    T
+	weights []float64
+}
+
+var ErrInvalidDims = fmt.Errorf("Number of weights does not match dimensions")
+
+// NewMulti returns a new MultiVaropt sampler with given capacity
+// (i.e., reservoir size), number of weight dimensions, and random
+// number generator.
+func NewMulti[T any](capacity, dims int, rnd *rand.Rand) *MultiVaropt[T] {
+	m := &MultiVaropt[T]{
+		dims:   dims,
+		totals: make([]float64, dims),
+	}
+	m.sampler.Init(capacity, rnd)
+	return m
+}
+
+// Add considers a new observation for the sample with one weight per
+// dimension.  Individual weights may be zero, but at least one must
+// be positive.  If there is an item ejected from the sample as a
+// result, the item is returned.
+//
+// An error will be returned if len(weights) does not equal the number
+// of dimensions or any weight is negative, NaN, or infinite.
+func (m *MultiVaropt[T]) Add(item T, weights []float64) (T, error) {
+	var zero T
+	if len(weights) != m.dims {
+		return zero, ErrInvalidDims
+	}
+	positive := false
+	for _, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 1) {
+			return zero, ErrInvalidWeight
+		}
+		positive = positive || w > 0
+	}
+	if !positive {
+		return zero, ErrInvalidWeight
+	}
+
+	// Normalize by the running means, including this item, so
+	// that each dimension's weights are on the same scale.
+	count := float64(m.sampler.TotalCount() + 1)
+	admit := 0.0
+	for d, w := range weights {
+		m.totals[d] += w
+		if w > 0 {
+			admit = math.Max(admit, w*count/m.totals[d])
+		}
+	}
+
+	eject, err := m.sampler.Add(multiSample[T]{
+		item:    item,
+		weights: append([]float64(nil), weights...),
+	}, admit)
+	return eject.item, err
+}
+
+// Get returns the i'th sample.
+func (m *MultiVaropt[T]) Get(i int) T {
+	ms, _ := m.sampler.Get(i)
+	return ms.item
+}
+
+// GetAdjustedWeight returns the adjusted weight of the i'th sample in
+// the given dimension.
+func (m *MultiVaropt[T]) GetAdjustedWeight(i, dim int) float64 {
+	ms, adjusted := m.sampler.Get(i)
+	return ms.weights[dim] * adjusted / m.sampler.GetOriginalWeight(i)
+}
+
+// EstimateSubsetSum returns an unbiased estimate of the sum of weights
+// in the given dimension over observations for which pred returns
+// true.
+func (m *MultiVaropt[T]) EstimateSubsetSum(dim int, pred func(T) bool) float64 {
+	sum := 0.0
+	for i := 0; i < m.sampler.Size(); i++ {
+		if pred(m.Get(i)) {
+			sum += m.GetAdjustedWeight(i, dim)
+		}
+	}
+	return sum
+}
+
+// Dims returns the number of weight dimensions.
+func (m *MultiVaropt[T]) Dims() int {
+	return m.dims
+}
+
+// Capacity returns the size of the reservoir.
+func (m *MultiVaropt[T]) Capacity() int {
+	return m.sampler.Capacity()
+}
+
+// Size returns the current number of items in the sample.
+func (m *MultiVaropt[T]) Size() int {
+	return m.sampler.Size()
+}
+
+// TotalWeight returns the sum of weights in the given dimension that
+// were passed to Add().
+func (m *MultiVaropt[T]) TotalWeight(dim int) float64 {
+	return m.totals[dim]
+}
+
+// TotalCount returns the number of calls to Add().
+func (m *MultiVaropt[T]) TotalCount() int {
+	return m.sampler.TotalCount()
+}
@@ -0,0 +1,115 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	color string
+}
+
+func TestMultiUnbiased(t *testing.T) {
+	const (
+		totalRequests = 1e6
+		sampleSize    = 10000
+	)
+
+	colors := []string{"red", "green", "blue"}
+	rnd := rand.New(rand.NewSource(32491))
+	sampler := varopt.NewMulti[request](sampleSize, 2, rnd)
+
+	trueSums := map[string][]float64{}
+	for _, c := range colors {
+		trueSums[c] = make([]float64, 2)
+	}
+
+	for i := 0; i < totalRequests; i++ {
+		r := request{color: colors[rnd.Intn(len(colors))]}
+		// Bytes and request count have unrelated scales.
+		weights := []float64{
+			1 + 1000*rnd.ExpFloat64(),
+			float64(1 + rnd.Intn(10)),
+		}
+		trueSums[r.color][0] += weights[0]
+		trueSums[r.color][1] += weights[1]
+
+		_, err := sampler.Add(r, weights)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, sampleSize, sampler.Size())
+	require.Equal(t, int(totalRequests), sampler.TotalCount())
+
+	for _, c := range colors {
+		for dim := 0; dim < 2; dim++ {
+			est := sampler.EstimateSubsetSum(dim, func(r request) bool {
+				return r.color == c
+			})
+			require.InEpsilon(t, trueSums[c][dim], est, epsilon)
+		}
+	}
+}
+
+func TestMultiScales(t *testing.T) {
+	const (
+		totalRequests = 100000
+		sampleSize    = 1000
+		rare          = 10000
+	)
+
+	rnd := rand.New(rand.NewSource(32491))
+	sampler := varopt.NewMulti[int](sampleSize, 2, rnd)
+
+	for i := 0; i < totalRequests; i++ {
+		// Dimension 0 is a million times larger than dimension
+		// 1, except for a few items that are small in dimension
+		// 0 but very large in dimension 1.
+		weights := []float64{1e6 * rnd.ExpFloat64(), rnd.ExpFloat64()}
+		if i%rare == rare/2 {
+			weights = []float64{1e3, 1e4}
+		}
+		_, err := sampler.Add(i, weights)
+		require.NoError(t, err)
+	}
+
+	// Items that dominate dimension 1 are retained with their exact
+	// weight, despite being small on the scale of dimension 0.
+	kept := 0
+	for i := 0; i < sampler.Size(); i++ {
+		if sampler.Get(i)%rare == rare/2 {
+			require.Equal(t, 1e4, sampler.GetAdjustedWeight(i, 1))
+			kept++
+		}
+	}
+	require.Equal(t, totalRequests/rare, kept)
+
+	for dim := 0; dim < 2; dim++ {
+		est := sampler.EstimateSubsetSum(dim, func(int) bool { return true })
+		require.InEpsilon(t, sampler.TotalWeight(dim), est, epsilon)
+	}
+}
+
+func TestMultiInvalid(t *testing.T) {
+	rnd := rand.New(rand.NewSource(32491))
+	sampler := varopt.NewMulti[request](10, 2, rnd)
+
+	_, err := sampler.Add(request{}, []float64{1})
+	require.Equal(t, varopt.ErrInvalidDims, err)
+
+	_, err = sampler.Add(request{}, []float64{0, 0})
+	require.Equal(t, varopt.ErrInvalidWeight, err)
+
+	_, err = sampler.Add(request{}, []float64{1, -1})
+	require.Equal(t, varopt.ErrInvalidWeight, err)
+
+	_, err = sampler.Add(request{}, []float64{0, 1})
+	require.NoError(t, err)
+	require.Equal(t, 0., sampler.TotalWeight(0))
+	require.Equal(t, 1., sampler.TotalWeight(1))
+}
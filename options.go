@@ -0,0 +1,100 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Option configures a sampler constructed by NewWith().
+type Option func(*options)
+
+type options struct {
+	rnd        Rand
+	naiveSum   bool
+	weightHint float64
+	landmark   time.Time
+	growth     func(age time.Duration) float64
+}
+
+// WithRand sets the source of randomness.  By default NewWith() uses
+// a generator seeded from the current time, as NewAutoSeed() does.
+func WithRand(rnd Rand) Option {
+	return func(o *options) {
+		o.rnd = rnd
+	}
+}
+
+// WithKahanSum controls whether TotalWeight() is accumulated using
+// compensated summation, which is the default.  Disabling it saves a
+// few operations per Add() at the cost of rounding error that grows
+// with the number of observations.
+func WithKahanSum(enabled bool) Option {
+	return func(o *options) {
+		o.naiveSum = !enabled
+	}
+}
+
+// WithInitialWeightHint starts TotalWeight() at weight rather than
+// zero, to account for weight observed before the sampler was
+// created, for example when resuming from saved totals.  The hint
+// must be zero or a valid weight.
+func WithInitialWeightHint(weight float64) Option {
+	return func(o *options) {
+		o.weightHint = weight
+	}
+}
+
+// WithForwardDecay enables forward decay, as described in "Forward
+// Decay: A Practical Time Decay Model for Streaming Systems" by
+// Cormode, Shkapenyuk, Srivastava and Xu (2009).  Observations passed
+// to AddAt() at time t are weighted by g(t - landmark), where g is a
+// positive, non-decreasing growth function such as the one returned
+// by ExponentialGrowth().  The landmark should not be later than the
+// observations.
+func WithForwardDecay(landmark time.Time, g func(age time.Duration) float64) Option {
+	return func(o *options) {
+		o.landmark = landmark
+		o.growth = g
+	}
+}
+
+// ExponentialGrowth returns the growth function 2^(age / halfLife),
+// for use with WithForwardDecay().  It gives exponential decay in
+// which an observation's weight halves every halfLife.  Since g grows
+// without bound, the landmark should be advanced, e.g., by starting a
+// new sampler, well before age reaches 1000 half-lives.
+func ExponentialGrowth(halfLife time.Duration) func(age time.Duration) float64 {
+	return func(age time.Duration) float64 {
+		return math.Exp2(float64(age) / float64(halfLife))
+	}
+}
+
+// NewWith returns a new Varopt sampler with given capacity, configured
+// by opts.  An error is returned if the capacity is not positive or
+// the weight hint is invalid.
+func NewWith[T any](capacity int, opts ...Option) (*Varopt[T], error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.rnd == nil {
+		o.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if err := validate(capacity, o.rnd); err != nil {
+		return nil, err
+	}
+	if o.weightHint != 0 && !validWeight(o.weightHint) {
+		return nil, ErrInvalidWeight
+	}
+
+	v := &Varopt[T]{}
+	v.init(capacity, o.rnd)
+	v.naiveSum = o.naiveSum
+	v.totalWeight = o.weightHint
+	v.landmark = o.landmark
+	v.growth = o.growth
+	return v, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithDefaults(t *testing.T) {
+	v, err := varopt.NewWith[testInt](100)
+	require.NoError(t, err)
+	require.Equal(t, 100, v.Capacity())
+
+	for i := 0; i < 1000; i++ {
+		_, err := v.Add(testInt(i), 1)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 100, v.Size())
+	require.Equal(t, 1000., v.TotalWeight())
+}
+
+func TestNewWithRand(t *testing.T) {
+	eq := func(a, b testInt) bool { return a == b }
+	v1 := varopt.New[testInt](100, rand.New(rand.NewSource(98887)))
+	v2, err := varopt.NewWith[testInt](100, varopt.WithRand(rand.New(rand.NewSource(98887))))
+	require.NoError(t, err)
+
+	vsrc := rand.New(rand.NewSource(98887))
+	for i := 0; i < 10000; i++ {
+		w := vsrc.ExpFloat64()
+		v1.Add(testInt(i), w)
+		v2.Add(testInt(i), w)
+	}
+	require.True(t, v1.Equal(v2, eq))
+
+	_, err = varopt.NewWith[testInt](0)
+	require.Equal(t, varopt.ErrInvalidCapacity, err)
+	_, err = varopt.NewWith[testInt](1, varopt.WithRand(nil))
+	require.NoError(t, err)
+}
+
+func TestNewWithKahanSum(t *testing.T) {
+	sum := func(opts ...varopt.Option) float64 {
+		v, err := varopt.NewWith[testInt](10, opts...)
+		require.NoError(t, err)
+		v.Add(0, 1e16)
+		for i := 0; i < 1000; i++ {
+			v.Add(0, 1)
+		}
+		return v.TotalWeight()
+	}
+	require.Equal(t, 1e16+1000, sum())
+	require.Equal(t, 1e16+1000, sum(varopt.WithKahanSum(true)))
+	require.Equal(t, 1e16, sum(varopt.WithKahanSum(false)))
+}
+
+func TestNewWithInitialWeightHint(t *testing.T) {
+	v, err := varopt.NewWith[testInt](10, varopt.WithInitialWeightHint(100))
+	require.NoError(t, err)
+	require.Equal(t, 100., v.TotalWeight())
+	require.Equal(t, 0, v.TotalCount())
+
+	v.Add(1, 2)
+	require.Equal(t, 102., v.TotalWeight())
+
+	_, err = varopt.NewWith[testInt](10, varopt.WithInitialWeightHint(math.NaN()))
+	require.Equal(t, varopt.ErrInvalidWeight, err)
+	_, err = varopt.NewWith[testInt](10, varopt.WithInitialWeightHint(-1))
+	require.Equal(t, varopt.ErrInvalidWeight, err)
+}
+
+func TestForwardDecay(t *testing.T) {
+	const halfLife = 100 * time.Second
+	landmark := time.Unix(1e9, 0)
+	v, err := varopt.NewWith[testInt](100,
+		varopt.WithRand(rand.New(rand.NewSource(98887))),
+		varopt.WithForwardDecay(landmark, varopt.ExponentialGrowth(halfLife)))
+	require.NoError(t, err)
+
+	const count = 10000
+	now := landmark
+	for i := 0; i < count; i++ {
+		now = landmark.Add(time.Duration(i) * time.Second)
+		_, err := v.AddAt(testInt(i), 1, now)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 100, v.Size())
+
+	// Nearly all of the retained items are recent.
+	recent := 0
+	for item := range v.All() {
+		if item >= count-testInt(5*halfLife/time.Second) {
+			recent++
+		}
+	}
+	require.Less(t, 95, recent)
+
+	// Σ 2^(-age/halfLife) over ages 0, 1s, 2s, ...
+	expect := 1 / (1 - math.Exp2(-float64(time.Second)/float64(halfLife)))
+	require.InEpsilon(t, expect, v.TotalWeight()*v.DecayScale(now), 1e-9)
+
+	w, err := varopt.NewWith[testInt](100)
+	require.NoError(t, err)
+	_, err = w.AddAt(1, 1, now)
+	require.Equal(t, varopt.ErrNoDecay, err)
+	require.Equal(t, 1., w.DecayScale(now))
+}
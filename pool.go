@@ -0,0 +1,42 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// SamplerPool holds reset samplers for reuse by GetSampler() and
+// PutSampler().  Samplers of every element type share the pool; a
+// pooled sampler of a different type than requested is discarded.
+var SamplerPool sync.Pool
+
+// GetSampler returns a sampler with given capacity and random number
+// generator, reusing the storage of a pooled sampler when one is
+// available.  The result behaves like one returned by New().
+func GetSampler[T any](capacity int, rnd *rand.Rand) *Varopt[T] {
+	mustValidate(capacity, fromRand(rnd))
+	v, ok := SamplerPool.Get().(*Varopt[T])
+	if !ok {
+		return New[T](capacity, rnd)
+	}
+	*v = Varopt[T]{
+		rnd: fromRand(rnd),
+		L:   v.L,
+		T:   v.T,
+		X:   v.X,
+		top: v.top,
+	}
+	v.ResetWithCapacity(capacity)
+	return v
+}
+
+// PutSampler clears a sampler and returns it to SamplerPool.  The
+// sampler must not be used after this call.  Sealed samplers may be
+// returned; PutSampler unseals them.
+func PutSampler[T any](v *Varopt[T]) {
+	v.sealed = false
+	v.ResetAndClear()
+	SamplerPool.Put(v)
+}
@@ -0,0 +1,89 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	const capacity = 100
+	const insert = 10000
+
+	fill := func(v *varopt.Varopt[testInt], vsrc *rand.Rand) {
+		for i := 0; i < insert; i++ {
+			v.Add(testInt(vsrc.Intn(insert)), vsrc.ExpFloat64())
+		}
+	}
+
+	used := varopt.GetSampler[testInt](capacity/2, rand.New(rand.NewSource(1)))
+	used.EnableTopWeights(3)
+	fill(used, rand.New(rand.NewSource(2)))
+	varopt.PutSampler(used)
+
+	pooled := varopt.GetSampler[testInt](capacity, rand.New(rand.NewSource(98887)))
+	fresh := varopt.New[testInt](capacity, rand.New(rand.NewSource(98887)))
+
+	require.Equal(t, capacity, pooled.Capacity())
+	require.Equal(t, 0, pooled.Size())
+	require.Equal(t, 0, pooled.TotalCount())
+
+	fill(pooled, rand.New(rand.NewSource(3)))
+	fill(fresh, rand.New(rand.NewSource(3)))
+
+	require.Empty(t, pooled.TopWeights())
+	require.Equal(t, fresh.Size(), pooled.Size())
+	require.Equal(t, fresh.TotalCount(), pooled.TotalCount())
+	require.Equal(t, fresh.TotalWeight(), pooled.TotalWeight())
+	require.Equal(t, fresh.Tau(), pooled.Tau())
+	for i := 0; i < capacity; i++ {
+		expectItem, expectWeight := fresh.Get(i)
+		item, weight := pooled.Get(i)
+		require.Equal(t, expectItem, item)
+		require.Equal(t, expectWeight, weight)
+	}
+}
+
+func TestPoolSealed(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.GetSampler[testInt](10, rnd)
+	for i := 0; i < 100; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	v.Seal()
+	require.NotPanics(t, func() { varopt.PutSampler(v) })
+
+	v = varopt.GetSampler[testInt](10, rnd)
+	require.False(t, v.Sealed())
+	require.Equal(t, 0, v.Size())
+	_, err := v.Add(1, 1)
+	require.NoError(t, err)
+}
+
+func BenchmarkSampler_Fresh(b *testing.B) {
+	benchmarkSampler(b, func(rnd *rand.Rand) *varopt.Varopt[thing] {
+		return varopt.New[thing](1000, rnd)
+	}, func(*varopt.Varopt[thing]) {})
+}
+
+func BenchmarkSampler_Pooled(b *testing.B) {
+	benchmarkSampler(b, func(rnd *rand.Rand) *varopt.Varopt[thing] {
+		return varopt.GetSampler[thing](1000, rnd)
+	}, varopt.PutSampler[thing])
+}
+
+func benchmarkSampler(b *testing.B, get func(*rand.Rand) *varopt.Varopt[thing], put func(*varopt.Varopt[thing])) {
+	b.ReportAllocs()
+	rnd := rand.New(rand.NewSource(3331))
+	for i := 0; i < b.N; i++ {
+		v := get(rnd)
+		for j := 0; j < 100; j++ {
+			v.Add(thing{}, 1)
+		}
+		put(v)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lightstep/varopt/internal"
+	"github.com/lightstep/varopt/varoptpb"
+)
+
+var ErrDuplicatePinned = fmt.Errorf("Duplicate first or last sample")
+
+var ErrInconsistentWeight = fmt.Errorf("Adjusted weight inconsistent with weight and threshold")
+
+// ToProto returns the sample, threshold and totals as a
+// varoptpb.Sampler, using encode to serialize each item.  As with
+// GobEncode(), configuration is not included.
+func (s *Varopt[T]) ToProto(encode func(T) []byte) *varoptpb.Sampler {
+	pb := &varoptpb.Sampler{
+		Capacity:    int64(s.capacity),
+		Tau:         s.tau,
+		TotalCount:  s.totalCount,
+		TotalWeight: s.TotalWeight(),
+		Samples:     make([]*varoptpb.Sample, 0, s.Size()),
+	}
+	add := func(v internal.Vsample[T], adjusted float64, kind varoptpb.Sample_Kind) {
+		pb.Samples = append(pb.Samples, &varoptpb.Sample{
+			Item:           encode(v.Sample),
+			Weight:         v.Weight,
+			AdjustedWeight: adjusted,
+			Kind:           kind,
+		})
+	}
+	for _, v := range s.L {
+		add(v, v.Weight, varoptpb.Sample_RESERVOIR)
+	}
+	for _, v := range s.T {
+		add(v, s.tau, varoptpb.Sample_RESERVOIR)
+	}
+	if s.hasFirst {
+		add(s.first, s.first.Weight, varoptpb.Sample_FIRST)
+	}
+	if s.hasLast {
+		add(s.last, s.last.Weight, varoptpb.Sample_LAST)
+	}
+	for _, v := range s.sticky {
+		add(v, v.Weight, varoptpb.Sample_STICKY)
+	}
+	return pb
+}
+
+// FromProto replaces the sample, threshold and totals with those of
+// pb, as produced by ToProto(), using decode to deserialize each item.
+// Reservoir samples whose adjusted weight equals their weight are
+// restored as large-weight items, even if they weigh less than the
+// threshold (as after Rescale()), and the rest as light items, which
+// must have the threshold as their adjusted weight and weigh less.
+// Pinned and sticky samples must carry their weight unadjusted.  Like
+// GobDecode(), the sampler keeps its configuration, and on error it is
+// not modified.
+func (s *Varopt[T]) FromProto(pb *varoptpb.Sampler, decode func([]byte) (T, error)) error {
+	if s.sealed {
+		return ErrSealed
+	}
+	capacity := int(pb.GetCapacity())
+	tau := pb.GetTau()
+	if capacity <= 0 {
+		return ErrInvalidCapacity
+	}
+	if tau < 0 || math.IsInf(tau, 0) || math.IsNaN(tau) {
+		return ErrInvalidWeight
+	}
+
+	var (
+		large, light      []internal.Vsample[T]
+		sticky            []internal.Vsample[T]
+		first, last       internal.Vsample[T]
+		hasFirst, hasLast bool
+	)
+	for _, sample := range pb.GetSamples() {
+		weight := sample.GetWeight()
+		if weight <= 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return ErrInvalidWeight
+		}
+		adjusted := sample.GetAdjustedWeight()
+		heavy := adjusted == weight
+		if !heavy && (sample.GetKind() != varoptpb.Sample_RESERVOIR ||
+			tau == 0 || adjusted != tau || weight > tau) {
+			return ErrInconsistentWeight
+		}
+		item, err := decode(sample.GetItem())
+		if err != nil {
+			return err
+		}
+		v := internal.Vsample[T]{Sample: item, Weight: weight}
+
+		switch sample.GetKind() {
+		case varoptpb.Sample_FIRST:
+			if hasFirst {
+				return ErrDuplicatePinned
+			}
+			first, hasFirst = v, true
+		case varoptpb.Sample_LAST:
+			if hasLast {
+				return ErrDuplicatePinned
+			}
+			last, hasLast = v, true
+		case varoptpb.Sample_STICKY:
+			sticky = append(sticky, v)
+		default:
+			if heavy {
+				large = append(large, v)
+			} else {
+				light = append(light, v)
+			}
+		}
+	}
+	if len(large)+len(light) > capacity {
+		return ErrInvalidCapacity
+	}
+
+	s.capacity = capacity
+	s.tau = tau
+	s.totalCount = pb.GetTotalCount()
+	s.totalWeight = pb.GetTotalWeight()
+	s.totalComp = 0
+	s.L, s.T, s.X = s.L[:0], s.T[:0], s.X[:0]
+	s.presize(s.capacity)
+	s.L = append(s.L, large...)
+	s.T = append(s.T, light...)
+	s.maxHeavy = len(s.L)
+	if s.tau != 0 {
+		// Before the first ejection L is kept in insertion
+		// order; after it, L must be a heap.
+		s.L.Init()
+	}
+	s.hasFirst, s.first = hasFirst, first
+	s.hasLast, s.last = hasLast, last
+	s.sticky = append(s.sticky[:0], sticky...)
+	return nil
+}
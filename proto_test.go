@@ -0,0 +1,157 @@
+// Copyright 2019, LightStep Inc.
+
+package varopt_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt"
+	"github.com/lightstep/varopt/varoptpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func encodeFloat(f float64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f))
+}
+
+func decodeFloat(data []byte) (float64, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("bad float length %d", len(data))
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[float64](100, rnd)
+	v.SetKeepFirst(true)
+	v.SetKeepLast(true)
+	for i := 0; i < 10000; i++ {
+		w := rnd.ExpFloat64()
+		if i%1000 == 0 {
+			w = 1e6
+		}
+		v.Add(float64(i), w)
+	}
+	require.NoError(t, v.AddSticky(-1, 1))
+
+	data, err := proto.Marshal(v.ToProto(encodeFloat))
+	require.NoError(t, err)
+
+	var pb varoptpb.Sampler
+	require.NoError(t, proto.Unmarshal(data, &pb))
+
+	r := varopt.New[float64](1, rand.New(rand.NewSource(98887)))
+	require.NoError(t, r.FromProto(&pb, decodeFloat))
+
+	eq := func(a, b float64) bool { return a == b }
+	require.True(t, v.Equal(r, eq))
+	require.Equal(t, v.NumHeavy(), r.NumHeavy())
+	require.Equal(t, v.TotalWeight(), r.TotalWeight())
+	for i := 0; i < v.Size(); i++ {
+		a, aw := v.Get(i)
+		b, bw := r.Get(i)
+		require.Equal(t, a, b)
+		require.Equal(t, aw, bw)
+		require.Equal(t, v.GetOriginalWeight(i), r.GetOriginalWeight(i))
+	}
+	require.NoError(t, varopt.CheckInvariants(r))
+
+	// The restored sampler accepts further observations.
+	for i := 10000; i < 11000; i++ {
+		r.Add(float64(i), rnd.ExpFloat64())
+	}
+	require.NoError(t, varopt.CheckInvariants(r))
+	require.Equal(t, v.TotalCount()+1000, r.TotalCount())
+}
+
+func TestProtoBeforeFull(t *testing.T) {
+	v := varopt.New[float64](10, rand.New(rand.NewSource(98887)))
+	for i := 0; i < 5; i++ {
+		v.Add(float64(i), float64(5-i))
+	}
+
+	r := varopt.New[float64](1, rand.New(rand.NewSource(98887)))
+	require.NoError(t, r.FromProto(v.ToProto(encodeFloat), decodeFloat))
+	for i := 0; i < v.Size(); i++ {
+		a, _ := v.Get(i)
+		b, _ := r.Get(i)
+		require.Equal(t, a, b)
+	}
+}
+
+func TestProtoAfterRescale(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[float64](100, rnd)
+	for i := 0; i < 1000; i++ {
+		w := rnd.ExpFloat64()
+		if i%100 == 0 {
+			w = 20
+		}
+		v.Add(float64(i), w)
+	}
+	// Halving the even items leaves large-weight items below the
+	// threshold.
+	v.Rescale(0.5, func(f float64) bool { return int(f)%2 == 0 })
+	require.Less(t, 0, v.NumHeavy())
+
+	r := varopt.New[float64](1, rand.New(rand.NewSource(98887)))
+	require.NoError(t, r.FromProto(v.ToProto(encodeFloat), decodeFloat))
+
+	eq := func(a, b float64) bool { return a == b }
+	require.True(t, v.Equal(r, eq))
+	require.Equal(t, v.NumHeavy(), r.NumHeavy())
+	require.Equal(t, v.NumLight(), r.NumLight())
+
+	sum := func(s *varopt.Varopt[float64]) float64 {
+		total := 0.
+		for _, w := range s.All() {
+			total += w
+		}
+		return total
+	}
+	require.InEpsilon(t, sum(v), sum(r), 1e-12)
+}
+
+func TestProtoInvalid(t *testing.T) {
+	r := varopt.New[float64](10, rand.New(rand.NewSource(98887)))
+	r.Add(1, 1)
+
+	require.Equal(t, varopt.ErrInvalidCapacity, r.FromProto(&varoptpb.Sampler{}, decodeFloat))
+
+	pb := &varoptpb.Sampler{
+		Capacity: 10,
+		Samples: []*varoptpb.Sample{
+			{Item: encodeFloat(1), Weight: 1, AdjustedWeight: 1, Kind: varoptpb.Sample_FIRST},
+			{Item: encodeFloat(2), Weight: 1, AdjustedWeight: 1, Kind: varoptpb.Sample_FIRST},
+		},
+	}
+	require.Equal(t, varopt.ErrDuplicatePinned, r.FromProto(pb, decodeFloat))
+
+	pb.Samples = []*varoptpb.Sample{{Item: encodeFloat(1), Weight: -1}}
+	require.Equal(t, varopt.ErrInvalidWeight, r.FromProto(pb, decodeFloat))
+
+	pb.Samples = []*varoptpb.Sample{{Item: []byte("x"), Weight: 1, AdjustedWeight: 1}}
+	require.Error(t, r.FromProto(pb, decodeFloat))
+
+	// Light samples carry the threshold and weigh less than it.
+	pb.Tau = 2
+	for _, sample := range []*varoptpb.Sample{
+		{Item: encodeFloat(1), Weight: 1, AdjustedWeight: 3},
+		{Item: encodeFloat(1), Weight: 3, AdjustedWeight: 2},
+		{Item: encodeFloat(1), Weight: 1, AdjustedWeight: 2, Kind: varoptpb.Sample_STICKY},
+	} {
+		pb.Samples = []*varoptpb.Sample{sample}
+		require.Equal(t, varopt.ErrInconsistentWeight, r.FromProto(pb, decodeFloat))
+	}
+	pb.Tau = 0
+	pb.Samples = []*varoptpb.Sample{{Item: encodeFloat(1), Weight: 1, AdjustedWeight: 2}}
+	require.Equal(t, varopt.ErrInconsistentWeight, r.FromProto(pb, decodeFloat))
+
+	require.Equal(t, 1, r.Size())
+}
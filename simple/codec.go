@@ -0,0 +1,77 @@
+// Copyright 2019, LightStep Inc.
+
+package simple
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+var ErrInvalidEncoding = fmt.Errorf("Invalid encoded sample")
+
+// Encode returns a binary encoding of the sampler's capacity, count
+// and sampled items, each item encoded by enc.  Use Decode() to
+// restore it.  The random number generator and algorithm are not
+// encoded.
+func (s *Simple[T]) Encode(enc func(T) []byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(s.capacity))
+	b = binary.AppendUvarint(b, uint64(s.observed))
+	b = binary.AppendUvarint(b, uint64(len(s.buffer)))
+	for _, item := range s.buffer {
+		e := enc(item)
+		b = binary.AppendUvarint(b, uint64(len(e)))
+		b = append(b, e...)
+	}
+	return b
+}
+
+// Decode replaces the state of the sampler with the output of
+// Encode(), decoding each item with dec.  The sampler keeps its
+// random number generator and algorithm, and subsequent calls to
+// Add() continue as though the encoded observations had been made
+// by this sampler.  ErrInvalidEncoding is returned if data is
+// malformed, and errors from dec are passed through; in either case
+// the sampler is not modified.
+func (s *Simple[T]) Decode(data []byte, dec func([]byte) (T, error)) error {
+	var header [3]uint64
+	for i := range header {
+		v, n := binary.Uvarint(data)
+		if n <= 0 || v > math.MaxInt {
+			return ErrInvalidEncoding
+		}
+		header[i] = v
+		data = data[n:]
+	}
+	capacity, observed, size := int(header[0]), int(header[1]), int(header[2])
+	// Each item takes at least its length byte, which bounds the
+	// allocation below by the size of data.
+	if size > capacity || size > observed || size > len(data) {
+		return ErrInvalidEncoding
+	}
+
+	// The buffer grows to capacity by Add(), so that a corrupt
+	// capacity cannot cause a large allocation here.
+	buffer := make([]T, 0, size)
+	for i := 0; i < size; i++ {
+		l, n := binary.Uvarint(data)
+		if n <= 0 || l > uint64(len(data)-n) {
+			return ErrInvalidEncoding
+		}
+		item, err := dec(data[n : n+int(l)])
+		if err != nil {
+			return err
+		}
+		buffer = append(buffer, item)
+		data = data[n+int(l):]
+	}
+	if len(data) != 0 {
+		return ErrInvalidEncoding
+	}
+
+	s.capacity = capacity
+	s.observed = observed
+	s.buffer = buffer
+	s.resume()
+	return nil
+}
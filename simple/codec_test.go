@@ -0,0 +1,112 @@
+// Copyright 2019, LightStep Inc.
+
+package simple_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/lightstep/varopt/simple"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeInt(i int) []byte {
+	return binary.AppendVarint(nil, int64(i))
+}
+
+func decodeInt(b []byte) (int, error) {
+	v, n := binary.Varint(b)
+	if n != len(b) {
+		return 0, fmt.Errorf("bad int")
+	}
+	return int(v), nil
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(17167))
+
+	for _, count := range []int{0, 10, 10000} {
+		ss := simple.New[int](100, rnd)
+		for i := 0; i < count; i++ {
+			ss.Add(i)
+		}
+
+		rs := simple.New[int](1, rnd)
+		require.NoError(t, rs.Decode(ss.Encode(encodeInt), decodeInt))
+		require.Equal(t, ss.Count(), rs.Count())
+		require.Equal(t, ss.Size(), rs.Size())
+		for i := 0; i < ss.Size(); i++ {
+			require.Equal(t, ss.Get(i), rs.Get(i))
+		}
+		require.Equal(t, ss.Encode(encodeInt), rs.Encode(encodeInt))
+	}
+}
+
+func TestCodecContinue(t *testing.T) {
+	const (
+		popSize    = 10000
+		sampleSize = 100
+		trials     = 1000
+		epsilon    = 0.05
+	)
+
+	for _, newSampler := range []func(int, *rand.Rand) *simple.Simple[int]{
+		simple.New[int],
+		simple.NewL[int],
+	} {
+		rnd := rand.New(rand.NewSource(17167))
+		later := 0
+
+		for trial := 0; trial < trials; trial++ {
+			ss := newSampler(sampleSize, rnd)
+			for i := 0; i < popSize; i++ {
+				ss.Add(i)
+			}
+			rs := newSampler(sampleSize, rnd)
+			require.NoError(t, rs.Decode(ss.Encode(encodeInt), decodeInt))
+
+			for i := popSize; i < 2*popSize; i++ {
+				rs.Add(i)
+			}
+			for item := range rs.All() {
+				if item >= popSize {
+					later++
+				}
+			}
+		}
+		require.InEpsilon(t, 0.5, float64(later)/(trials*sampleSize), epsilon)
+	}
+}
+
+func TestCodecInvalid(t *testing.T) {
+	rnd := rand.New(rand.NewSource(17167))
+	ss := simple.New[int](10, rnd)
+	for i := 0; i < 100; i++ {
+		ss.Add(i)
+	}
+	data := ss.Encode(encodeInt)
+
+	rs := simple.New[int](10, rnd)
+	rs.Add(-1)
+	for _, bad := range [][]byte{
+		nil,
+		data[:len(data)-1],
+		append(data[:len(data):len(data)], 0),
+		// Capacity 1 with 2 items.
+		{1, 2, 2, 1, 0, 1, 0},
+		// Capacity, count and size of 1<<62 with no items.
+		binary.AppendUvarint(binary.AppendUvarint(binary.AppendUvarint(nil, 1<<62), 1<<62), 1<<62),
+	} {
+		require.Equal(t, simple.ErrInvalidEncoding, rs.Decode(bad, decodeInt))
+	}
+
+	decodeErr := fmt.Errorf("refused")
+	require.Equal(t, decodeErr, rs.Decode(data, func([]byte) (int, error) {
+		return 0, decodeErr
+	}))
+
+	require.Equal(t, 1, rs.Count())
+	require.Equal(t, -1, rs.Get(0))
+}
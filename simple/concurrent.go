@@ -0,0 +1,64 @@
+// Copyright 2019, LightStep Inc.
+
+package simple
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Concurrent is a Simple sampler that is safe for use by multiple
+// goroutines.  Simple itself is not: Add() replaces items in place
+// and draws from an unsynchronized random number generator.
+// Concurrent guards every operation with a mutex and offers
+// Snapshot() for reading the whole sample consistently.
+type Concurrent[T any] struct {
+	lock    sync.Mutex
+	sampler Simple[T]
+}
+
+// NewConcurrent returns a new Concurrent sampler with given capacity
+// and random number generator.  The generator must not be used
+// elsewhere.
+func NewConcurrent[T any](capacity int, rnd *rand.Rand) *Concurrent[T] {
+	c := &Concurrent[T]{}
+	c.sampler.Init(capacity, rnd)
+	return c
+}
+
+// Add considers a new observation for the sample; see Simple.Add().
+func (c *Concurrent[T]) Add(item T) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sampler.Add(item)
+}
+
+// Get returns the i'th selected item from the sample.  Indexes are
+// only meaningful between calls to Add(); use Snapshot() to read the
+// whole sample while other goroutines are adding.
+func (c *Concurrent[T]) Get(i int) T {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.sampler.Get(i)
+}
+
+// Size returns the number of items in the sample.
+func (c *Concurrent[T]) Size() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.sampler.Size()
+}
+
+// Count returns the number of items that were observed.
+func (c *Concurrent[T]) Count() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.sampler.Count()
+}
+
+// Snapshot returns a copy of the current sample.
+func (c *Concurrent[T]) Snapshot() []T {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]T(nil), c.sampler.buffer...)
+}
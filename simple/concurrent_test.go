@@ -0,0 +1,64 @@
+// Copyright 2019, LightStep Inc.
+
+package simple_test
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/lightstep/varopt/simple"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrent(t *testing.T) {
+	const (
+		capacity = 100
+		writers  = 8
+		perWrite = 10000
+	)
+
+	c := simple.NewConcurrent[int](capacity, rand.New(rand.NewSource(17167)))
+
+	var writeWG, readWG sync.WaitGroup
+	done := make(chan struct{})
+
+	readWG.Add(1)
+	go func() {
+		defer readWG.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			snap := c.Snapshot()
+			assert.LessOrEqual(t, len(snap), capacity)
+			for _, item := range snap {
+				assert.True(t, item >= 0 && item < writers*perWrite)
+			}
+		}
+	}()
+
+	for w := 0; w < writers; w++ {
+		writeWG.Add(1)
+		go func(w int) {
+			defer writeWG.Done()
+			for i := 0; i < perWrite; i++ {
+				c.Add(w*perWrite + i)
+			}
+		}(w)
+	}
+
+	writeWG.Wait()
+	close(done)
+	readWG.Wait()
+
+	require.Equal(t, capacity, c.Size())
+	require.Equal(t, writers*perWrite, c.Count())
+
+	snap := c.Snapshot()
+	require.Equal(t, capacity, len(snap))
+	require.Equal(t, snap[0], c.Get(0))
+}
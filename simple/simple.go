@@ -3,17 +3,34 @@
 package simple
 
 import (
+	"fmt"
+	"iter"
+	"math"
 	"math/rand"
 )
 
 // Simple implements unweighted reservoir sampling using Algorithm R
 // from "Random sampling with a reservoir" by Jeffrey Vitter (1985)
 // https://en.wikipedia.org/wiki/Reservoir_sampling#Algorithm_R
+//
+// Samplers created by NewL() instead use Algorithm L, from "Reservoir
+// Algorithms with Optimal Time Complexity" by Kim-Hung Li (1994)
+// https://en.wikipedia.org/wiki/Reservoir_sampling#Optimal:_Algorithm_L
+// which yields the same distribution of samples.
+//
+// A Simple is not safe for concurrent use; see Concurrent.
 type Simple[T any] struct {
 	capacity int
 	observed int
 	buffer   []T
 	rnd      *rand.Rand
+
+	// Algorithm L state: the largest of the capacity smallest
+	// uniform keys seen, and the observation count at which the
+	// next item enters the sample.
+	algL bool
+	w    float64
+	next int
 }
 
 // New returns a simple reservoir sampler with given capacity
@@ -24,14 +41,42 @@ func New[T any](capacity int, rnd *rand.Rand) *Simple[T] {
 	return s
 }
 
+// NewL returns a simple reservoir sampler like New(), using Algorithm
+// L.  Rather than drawing a random number for every observation once
+// the reservoir is full, Algorithm L draws the number of observations
+// to skip before the next replacement, so that the number of random
+// draws grows with the logarithm of the stream length.  Init()
+// returns the sampler to Algorithm R.
+func NewL[T any](capacity int, rnd *rand.Rand) *Simple[T] {
+	s := New[T](capacity, rnd)
+	s.algL = true
+	return s
+}
+
 func (s *Simple[T]) Init(capacity int, rnd *rand.Rand) {
 	*s = Simple[T]{
 		capacity: capacity,
-		buffer:   make([]T, 0, s.capacity),
+		buffer:   make([]T, 0, capacity),
 		rnd:      rnd,
 	}
 }
 
+// CopyFrom copies the fields of `from` into this Simple[T].  The
+// buffer is copied, reusing this sampler's storage when it is large
+// enough.
+func (s *Simple[T]) CopyFrom(from *Simple[T]) {
+	cpy := *from
+	cpy.buffer = append(s.buffer[:0], from.buffer...)
+	*s = cpy
+}
+
+// Reset returns the sampler to its initial state, maintaining its
+// capacity, storage and random number generator.
+func (s *Simple[T]) Reset() {
+	s.observed = 0
+	s.buffer = s.buffer[:0]
+}
+
 // Add considers a new observation for the sample.  Items have unit
 // weight.
 func (s *Simple[T]) Add(item T) {
@@ -39,6 +84,18 @@ func (s *Simple[T]) Add(item T) {
 
 	if len(s.buffer) < s.capacity {
 		s.buffer = append(s.buffer, item)
+		if s.algL && len(s.buffer) == s.capacity {
+			s.w = 1
+			s.advance()
+		}
+		return
+	}
+
+	if s.algL {
+		if s.observed == s.next {
+			s.buffer[s.rnd.Intn(s.capacity)] = item
+			s.advance()
+		}
 		return
 	}
 
@@ -49,11 +106,65 @@ func (s *Simple[T]) Add(item T) {
 	}
 }
 
-// Get returns the i'th selected item from the sample.
+// advance updates the Algorithm L state after an item enters the
+// full reservoir.
+func (s *Simple[T]) advance() {
+	s.w *= math.Exp(math.Log(s.uniform()) / float64(s.capacity))
+	s.skip()
+}
+
+// skip computes the observation count at which the next item enters
+// the sample, skipping those that will not.
+func (s *Simple[T]) skip() {
+	skip := math.Floor(math.Log(s.uniform()) / math.Log1p(-s.w))
+	if skip >= float64(math.MaxInt-s.observed-1) {
+		s.next = math.MaxInt
+		return
+	}
+	s.next = s.observed + int(skip) + 1
+}
+
+// uniform returns a random number in (0, 1).
+func (s *Simple[T]) uniform() float64 {
+	for {
+		r := s.rnd.Float64()
+		if r != 0.0 {
+			return r
+		}
+	}
+}
+
+// Get returns the i'th selected item from the sample.  Get panics if
+// i is not in [0, Size()); see TryGet().
 func (s *Simple[T]) Get(i int) T {
+	if i < 0 || i >= len(s.buffer) {
+		panic(fmt.Sprintf("simple: index %d out of range with Size() %d", i, len(s.buffer)))
+	}
 	return s.buffer[i]
 }
 
+// TryGet is like Get(), but returns false instead of panicking when i
+// is out of range.
+func (s *Simple[T]) TryGet(i int) (T, bool) {
+	if i < 0 || i >= len(s.buffer) {
+		var zero T
+		return zero, false
+	}
+	return s.buffer[i], true
+}
+
+// All returns an iterator over the sampled items, in the order of
+// Get().
+func (s *Simple[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, item := range s.buffer {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
 // Size returns the number of items in the sample.  If the reservoir is
 // full, Size() equals Capacity().
 func (s *Simple[T]) Size() int {
@@ -64,3 +175,85 @@ func (s *Simple[T]) Size() int {
 func (s *Simple[T]) Count() int {
 	return s.observed
 }
+
+// Weight returns the adjusted weight of each item in the sample,
+// Count() divided by Size(), so that summing Weight() times an item's
+// value over the sample estimates the sum over all observations.  It
+// returns 0 for an empty sample.
+func (s *Simple[T]) Weight() float64 {
+	if len(s.buffer) == 0 {
+		return 0
+	}
+	return float64(s.observed) / float64(len(s.buffer))
+}
+
+// Merge combines the sample of other into this sampler, producing a
+// uniform sample of the observations of both, as though they had
+// been added to a single sampler.  Count() becomes the sum of both
+// counts.  The number of items taken from each sample is drawn as if
+// sampling without replacement from the combined observations, and
+// that many are chosen at random from each.  The capacity of other
+// should be no less than this sampler's; otherwise it may hold too
+// few items to supply its share, and the result favors this sampler's
+// observations.  Other is not modified and must not be the same
+// sampler.
+func (s *Simple[T]) Merge(other *Simple[T]) {
+	total := s.observed + other.observed
+	size := min(s.capacity, len(s.buffer)+len(other.buffer))
+
+	mine, theirs := 0, 0
+	for mine+theirs < size {
+		drawn := mine + theirs
+		if mine < len(s.buffer) &&
+			(theirs == len(other.buffer) || s.rnd.Intn(total-drawn) < s.observed-mine) {
+			mine++
+		} else {
+			theirs++
+		}
+	}
+
+	// A random subset of each sample is a uniform sample of the
+	// corresponding observations.
+	for i := 0; i < mine; i++ {
+		j := i + s.rnd.Intn(len(s.buffer)-i)
+		s.buffer[i], s.buffer[j] = s.buffer[j], s.buffer[i]
+	}
+	s.buffer = s.buffer[:mine]
+	for _, j := range s.rnd.Perm(len(other.buffer))[:theirs] {
+		s.buffer = append(s.buffer, other.buffer[j])
+	}
+	s.observed = total
+
+	s.resume()
+}
+
+// resume sets the Algorithm L state for a reservoir whose contents and
+// count were assigned directly, rather than by Add().
+func (s *Simple[T]) resume() {
+	if s.algL && len(s.buffer) == s.capacity {
+		// The Algorithm L threshold after n observations is
+		// the capacity'th smallest of n uniform keys.
+		k := float64(s.capacity)
+		x := s.gamma(k)
+		s.w = x / (x + s.gamma(float64(s.observed)-k+1))
+		s.skip()
+	}
+}
+
+// gamma returns a Gamma(a, 1) random variable, a >= 1, using the
+// method of Marsaglia and Tsang (2000).
+func (s *Simple[T]) gamma(a float64) float64 {
+	d := a - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := s.rnd.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		if math.Log(s.uniform()) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
@@ -31,9 +31,320 @@ func TestSimple(t *testing.T) {
 	require.Equal(t, ss.Size(), sampleSize)
 
 	ssum := 0.0
-	for i := 0; i < sampleSize; i++ {
+	for item := range ss.All() {
+		ssum += float64(item)
+	}
+
+	require.InEpsilon(t, ssum/float64(ss.Size()), psum/popSize, epsilon)
+}
+
+func TestSimpleReset(t *testing.T) {
+	const (
+		popSize    = 100000
+		sampleSize = 1000
+		epsilon    = 0.05
+	)
+
+	rnd := rand.New(rand.NewSource(17167))
+	ss := simple.New[int](sampleSize, rnd)
+
+	for i := 0; i < popSize; i++ {
+		ss.Add(i)
+	}
+	ss.Reset()
+	require.Equal(t, 0, ss.Size())
+	require.Equal(t, 0, ss.Count())
+
+	i := 0
+	require.Equal(t, 0., testing.AllocsPerRun(popSize, func() {
+		ss.Add(i)
+		i++
+	}))
+	require.Equal(t, sampleSize, ss.Size())
+	require.Equal(t, popSize+1, ss.Count())
+
+	ssum := 0.0
+	for i := 0; i < ss.Size(); i++ {
 		ssum += float64(ss.Get(i))
 	}
+	require.InEpsilon(t, popSize/2, ssum/float64(ss.Size()), epsilon)
+}
+
+func TestSimpleWeight(t *testing.T) {
+	const (
+		popSize    = 100000
+		sampleSize = 1000
+		epsilon    = 0.05
+	)
+
+	rnd := rand.New(rand.NewSource(17167))
+	ss := simple.New[int](sampleSize, rnd)
+	require.Equal(t, 0., ss.Weight())
 
+	ss.Add(1)
+	require.Equal(t, 1., ss.Weight())
+
+	ss.Reset()
+	psum := 0.
+	for i := 0; i < popSize; i++ {
+		ss.Add(i)
+		psum += float64(i)
+	}
+	require.Equal(t, float64(popSize)/sampleSize, ss.Weight())
+
+	ssum := 0.
+	for item := range ss.All() {
+		ssum += ss.Weight() * float64(item)
+	}
+	require.InEpsilon(t, psum, ssum, epsilon)
+}
+
+func TestSimpleLUniform(t *testing.T) {
+	const (
+		popSize    = 100
+		sampleSize = 10
+		trials     = 20000
+		expect     = trials * sampleSize / popSize
+	)
+
+	for _, newSampler := range []func(int, *rand.Rand) *simple.Simple[int]{
+		simple.New[int],
+		simple.NewL[int],
+	} {
+		rnd := rand.New(rand.NewSource(17167))
+		counts := make([]int, popSize)
+
+		for trial := 0; trial < trials; trial++ {
+			ss := newSampler(sampleSize, rnd)
+			for i := 0; i < popSize; i++ {
+				ss.Add(i)
+			}
+			require.Equal(t, sampleSize, ss.Size())
+			for i := 0; i < ss.Size(); i++ {
+				counts[ss.Get(i)]++
+			}
+		}
+
+		// Each item's count is binomial with standard deviation
+		// about 42; allow nearly 5 deviations.
+		for i, c := range counts {
+			require.InDelta(t, expect, c, 200, "item %d", i)
+		}
+	}
+}
+
+func TestSimpleL(t *testing.T) {
+	const (
+		popSize        = 1e6
+		sampleProb     = 0.1
+		sampleSize int = popSize * sampleProb
+		epsilon        = 0.01
+	)
+
+	rnd := rand.New(rand.NewSource(17167))
+	ss := simple.NewL[int](sampleSize, rnd)
+
+	psum := 0.
+	for i := 0; i < popSize; i++ {
+		ss.Add(i)
+		psum += float64(i)
+	}
+	require.Equal(t, sampleSize, ss.Size())
+	require.Equal(t, int(popSize), ss.Count())
+
+	ssum := 0.0
+	for i := 0; i < sampleSize; i++ {
+		ssum += float64(ss.Get(i))
+	}
 	require.InEpsilon(t, ssum/float64(ss.Size()), psum/popSize, epsilon)
 }
+
+// countingSource counts the values drawn from a rand.Source.
+type countingSource struct {
+	rand.Source
+	calls int
+}
+
+func (c *countingSource) Int63() int64 {
+	c.calls++
+	return c.Source.Int63()
+}
+
+func benchmarkSimple(b *testing.B, newSampler func(int, *rand.Rand) *simple.Simple[int]) {
+	src := &countingSource{Source: rand.NewSource(17167)}
+	ss := newSampler(1000, rand.New(src))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ss.Add(i)
+	}
+	b.ReportMetric(float64(src.calls)/float64(b.N), "draws/op")
+}
+
+func BenchmarkSimpleR(b *testing.B) {
+	benchmarkSimple(b, simple.New[int])
+}
+
+func BenchmarkSimpleL(b *testing.B) {
+	benchmarkSimple(b, simple.NewL[int])
+}
+
+func TestSimpleMerge(t *testing.T) {
+	const (
+		popSize    = 10000
+		split      = 3000
+		sampleSize = 100
+		trials     = 1000
+		epsilon    = 0.05
+	)
+
+	for _, newSampler := range []func(int, *rand.Rand) *simple.Simple[int]{
+		simple.New[int],
+		simple.NewL[int],
+	} {
+		rnd := rand.New(rand.NewSource(17167))
+		first, second, later := 0, 0, 0
+
+		for trial := 0; trial < trials; trial++ {
+			s1 := newSampler(sampleSize, rnd)
+			s2 := newSampler(sampleSize, rnd)
+			for i := 0; i < popSize; i++ {
+				if i < split {
+					s1.Add(i)
+				} else {
+					s2.Add(i)
+				}
+			}
+			s1.Merge(s2)
+			require.Equal(t, popSize, s1.Count())
+			require.Equal(t, sampleSize, s1.Size())
+
+			for i := 0; i < s1.Size(); i++ {
+				if s1.Get(i) < split {
+					first++
+				} else {
+					second++
+				}
+			}
+
+			// Continuing after the merge, half of the
+			// sample should come from later observations.
+			for i := popSize; i < 2*popSize; i++ {
+				s1.Add(i)
+			}
+			for i := 0; i < s1.Size(); i++ {
+				if s1.Get(i) >= popSize {
+					later++
+				}
+			}
+		}
+
+		total := float64(trials * sampleSize)
+		require.InEpsilon(t, float64(split)/popSize, float64(first)/total, epsilon)
+		require.InEpsilon(t, float64(popSize-split)/popSize, float64(second)/total, epsilon)
+		require.InEpsilon(t, 0.5, float64(later)/total, epsilon)
+	}
+}
+
+func TestSimpleMergeSmall(t *testing.T) {
+	rnd := rand.New(rand.NewSource(17167))
+	s1 := simple.New[int](10, rnd)
+	s2 := simple.New[int](10, rnd)
+	for i := 0; i < 3; i++ {
+		s1.Add(i)
+		s2.Add(10 + i)
+	}
+	s1.Merge(s2)
+	require.Equal(t, 6, s1.Size())
+	require.Equal(t, 6, s1.Count())
+	require.Equal(t, 3, s2.Size())
+
+	var items []int
+	for i := 0; i < s1.Size(); i++ {
+		items = append(items, s1.Get(i))
+	}
+	require.ElementsMatch(t, []int{0, 1, 2, 10, 11, 12}, items)
+}
+
+func TestSimpleAll(t *testing.T) {
+	rnd := rand.New(rand.NewSource(17167))
+	ss := simple.New[int](100, rnd)
+	for i := 0; i < 10000; i++ {
+		ss.Add(i)
+	}
+
+	var items []int
+	for item := range ss.All() {
+		items = append(items, item)
+	}
+	require.Equal(t, ss.Size(), len(items))
+	for i, item := range items {
+		require.Equal(t, ss.Get(i), item)
+	}
+
+	for range ss.All() {
+		break
+	}
+
+	sum := 0
+	require.Equal(t, 0., testing.AllocsPerRun(10, func() {
+		for item := range ss.All() {
+			sum += item
+		}
+	}))
+}
+
+func TestSimpleCopyFrom(t *testing.T) {
+	const (
+		capacity = 10
+		insert   = 100
+	)
+	rnd := rand.New(rand.NewSource(17167))
+	ss := simple.New[int](capacity, rnd)
+	for i := 0; i < insert; i++ {
+		ss.Add(i)
+	}
+
+	var s2 simple.Simple[int]
+	s2.Init(capacity, rnd)
+	s2.CopyFrom(ss)
+
+	var expect []int
+	for item := range ss.All() {
+		expect = append(expect, item)
+	}
+
+	ss.Reset()
+	for i := 0; i < capacity; i++ {
+		ss.Add(-i)
+	}
+	require.Equal(t, capacity, ss.Count())
+
+	require.Equal(t, capacity, s2.Size())
+	require.Equal(t, insert, s2.Count())
+
+	var have []int
+	for item := range s2.All() {
+		have = append(have, item)
+	}
+	require.Equal(t, expect, have)
+}
+
+func TestSimpleGetOutOfRange(t *testing.T) {
+	ss := simple.New[int](10, rand.New(rand.NewSource(17167)))
+	for i := 0; i < 5; i++ {
+		ss.Add(i)
+	}
+
+	item, ok := ss.TryGet(4)
+	require.True(t, ok)
+	require.Equal(t, ss.Get(4), item)
+
+	for _, i := range []int{-1, 5, 10} {
+		_, ok := ss.TryGet(i)
+		require.False(t, ok)
+	}
+	require.PanicsWithValue(t, "simple: index -1 out of range with Size() 5", func() { ss.Get(-1) })
+	require.PanicsWithValue(t, "simple: index 5 out of range with Size() 5", func() { ss.Get(5) })
+}
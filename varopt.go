@@ -3,9 +3,15 @@
 package varopt
 
 import (
+	"cmp"
 	"fmt"
+	"io"
+	"iter"
 	"math"
 	"math/rand"
+	"slices"
+	"sort"
+	"time"
 
 	"github.com/lightstep/varopt/internal"
 )
@@ -15,9 +21,17 @@ import (
 // Duffield, Haim Kaplan, Carsten Lund, Mikkel Thorup 2008
 //
 // https://arxiv.org/pdf/0803.0473.pdf
+//
+// A Varopt is not safe for concurrent use; see Concurrent.
+//
+// Storage for the reservoir is allocated up front for the capacity
+// given to New(), Init() or ResetWithCapacity(), so that Add() does
+// not allocate once the sampler is constructed, other than to record
+// TopWeights().  Growing the capacity with Resize() allocates as
+// needed.
 type Varopt[T any] struct {
 	// Random number generator
-	rnd *rand.Rand
+	rnd Rand
 
 	// Large-weight items stored in a min-heap.
 	L internal.SampleHeap[T]
@@ -34,80 +48,652 @@ type Varopt[T any] struct {
 	// Size of sample & scale
 	capacity int
 
-	totalCount  int
+	totalCount  int64
 	totalWeight float64
+	// Compensation for the rounding error in totalWeight, see
+	// addWeight(), unless disabled by WithKahanSum(false).
+	totalComp float64
+	naiveSum  bool
+
+	// Item hash and seed, used by samplers constructed with
+	// NewHashed() to derive randomness from the input.
+	hash     func(T) uint64
+	hashSeed uint64
+	hashSrc  *hashSource
+
+	// Exact highest-weight observations, when enabled.
+	topK int
+	top  internal.SampleHeap[T]
+
+	// Bounds on admitted values, when set, and the function that
+	// brings out-of-bounds items within them instead of excluding
+	// them, if any.
+	value   func(T) float64
+	valueLo float64
+	valueHi float64
+	clamp   func(item T, lo, hi float64) T
+
+	// Pinned earliest and most recent observations, when enabled.
+	keepFirst bool
+	keepLast  bool
+	hasFirst  bool
+	hasLast   bool
+	first     internal.Vsample[T]
+	last      internal.Vsample[T]
+
+	// Items added by AddSticky().
+	sticky []internal.Vsample[T]
+
+	// Eject the newest light item rather than a random one.
+	stable bool
+
+	// Order for breaking ties independently of arrival order, when
+	// set.
+	less func(a, b T) bool
+
+	// Called for each item leaving the sample, when set.
+	onEject func(item T, originalWeight float64)
+
+	// Random number draws, when auditing is enabled.
+	audit      bool
+	floatDraws int
+	intDraws   int
+
+	// High-water mark of len(L).
+	maxHeavy int
+
+	// Forward decay landmark and growth function, when set by
+	// WithForwardDecay().
+	landmark time.Time
+	growth   func(age time.Duration) float64
+
+	// Read-only, after Seal().
+	sealed bool
+}
+
+// Sampled is an item with its weight.
+type Sampled[T any] struct {
+	Item   T
+	Weight float64
+}
+
+// Weighted is a sampled item with its adjusted and original weights
+// and the probability with which it was included, as returned by
+// GetWeighted().
+type Weighted[T any] struct {
+	Item                 T
+	AdjustedWeight       float64
+	OriginalWeight       float64
+	InclusionProbability float64
 }
 
 var ErrInvalidWeight = fmt.Errorf("Negative, Zero, Inf or NaN weight")
 
+var ErrLengthMismatch = fmt.Errorf("Number of items and weights differ")
+
+var ErrNotEmpty = fmt.Errorf("Sampler has observations")
+
+var ErrCapacityMismatch = fmt.Errorf("Samplers have different capacity")
+
+var ErrNilRand = fmt.Errorf("Nil random number generator")
+
+var ErrInvalidCapacity = fmt.Errorf("Zero or negative capacity")
+
+var ErrNoDecay = fmt.Errorf("Sampler has no forward decay")
+
+var ErrSealed = fmt.Errorf("Sampler is sealed")
+
+var ErrSelfMerge = fmt.Errorf("Sampler merged into itself")
+
+// Rand is the source of randomness used by a Varopt sampler.  It is
+// satisfied by *rand.Rand.
+type Rand interface {
+	Intn(n int) int
+	Float64() float64
+}
+
 // New returns a new Varopt sampler with given capacity (i.e.,
-// reservoir size) and random number generator.
+// reservoir size) and random number generator.  New panics if the
+// capacity is not positive or rnd is nil; see TryNew().
 func New[T any](capacity int, rnd *rand.Rand) *Varopt[T] {
+	mustValidate(capacity, fromRand(rnd))
+	v, _ := NewWith[T](capacity, WithRand(fromRand(rnd)))
+	return v
+}
+
+// TryNew is like New(), but returns ErrInvalidCapacity or ErrNilRand
+// instead of panicking on invalid input.
+func TryNew[T any](capacity int, rnd *rand.Rand) (*Varopt[T], error) {
+	if err := validate(capacity, fromRand(rnd)); err != nil {
+		return nil, err
+	}
+	return New[T](capacity, rnd), nil
+}
+
+// NewWithRand returns a new Varopt sampler with given capacity and
+// source of randomness.  Like New(), it panics on invalid input.
+func NewWithRand[T any](capacity int, rnd Rand) *Varopt[T] {
 	v := &Varopt[T]{}
-	v.Init(capacity, rnd)
+	v.init(capacity, rnd)
 	return v
 }
 
+// NewAutoSeed returns a new Varopt sampler with given capacity and a
+// random number generator seeded from the current time.  The seed is
+// returned so that it can be logged; passing
+// rand.New(rand.NewSource(seed)) to New() reproduces the run.
+//
+// Like every *rand.Rand created by rand.New(), the generator is not
+// safe for concurrent use, and neither is the sampler.
+func NewAutoSeed[T any](capacity int) (*Varopt[T], int64) {
+	seed := time.Now().UnixNano()
+	return New[T](capacity, rand.New(rand.NewSource(seed))), seed
+}
+
 // Init initializes a Varopt[T] in-place, avoiding an allocation
-// compared with New().
+// compared with New().  Like New(), it panics on invalid input.
 func (v *Varopt[T]) Init(capacity int, rnd *rand.Rand) {
+	v.init(capacity, fromRand(rnd))
+}
+
+func (v *Varopt[T]) init(capacity int, rnd Rand) {
+	mustValidate(capacity, rnd)
 	*v = Varopt[T]{
 		capacity: capacity,
 		rnd:      rnd,
-		L:        make(internal.SampleHeap[T], 0, capacity),
-		T:        make(internal.SampleHeap[T], 0, capacity),
+	}
+	v.presize(capacity)
+}
+
+// presize ensures that L, T and X need not grow while the reservoir
+// holds at most capacity items.  L and X each hold one more item than
+// capacity while an item is being added.  The buffers must be empty.
+func (v *Varopt[T]) presize(capacity int) {
+	if cap(v.L) < capacity+1 {
+		v.L = make(internal.SampleHeap[T], 0, capacity+1)
+	}
+	if cap(v.T) < capacity {
+		v.T = make([]internal.Vsample[T], 0, capacity)
+	}
+	if cap(v.X) < capacity+1 {
+		v.X = make([]internal.Vsample[T], 0, capacity+1)
+	}
+}
+
+// validate checks the arguments used to construct a sampler.
+func validate(capacity int, rnd Rand) error {
+	if capacity <= 0 {
+		return ErrInvalidCapacity
+	}
+	if rnd == nil {
+		return ErrNilRand
+	}
+	return nil
+}
+
+// mustValidate panics if validate() fails.
+func mustValidate(capacity int, rnd Rand) {
+	if err := validate(capacity, rnd); err != nil {
+		panic(fmt.Sprintf("varopt: %v (capacity %d)", err, capacity))
 	}
 }
 
 // Reset returns the sampler to its initial state, maintaining its
-// capacity and random number source.
+// capacity and random number source.  See also ResetReservoir().
 func (s *Varopt[T]) Reset() {
+	s.ResetReservoir()
+	s.totalCount = 0
+	s.totalWeight = 0
+	s.totalComp = 0
+	s.top = s.top[:0]
+	s.maxHeavy = 0
+}
+
+// ResetReservoir empties the sample, including pinned and sticky
+// items, and resets the threshold, but unlike Reset() keeps the
+// lifetime statistics: TotalCount(), TotalWeight() and TopWeights()
+// continue to include earlier observations.  This suits reporting a fresh
+// sample for each interval of a stream.  Note that estimates from the
+// new sample cover only later observations, while the totals do not.
+func (s *Varopt[T]) ResetReservoir() {
+	s.mustNotBeSealed()
+	if s.onEject != nil {
+		for _, v := range s.L {
+			s.ejected(v)
+		}
+		for _, v := range s.T {
+			s.ejected(v)
+		}
+		for i := 0; i < s.numPinned(); i++ {
+			s.ejected(s.pinned(i))
+		}
+	}
 	s.L = s.L[:0]
 	s.T = s.T[:0]
 	s.X = s.X[:0]
 	s.tau = 0
-	s.totalCount = 0
-	s.totalWeight = 0
+	s.hasFirst = false
+	s.hasLast = false
+	s.sticky = s.sticky[:0]
+}
+
+// ResetAndClear is like Reset, but also zeroes the backing storage so
+// that the sampler holds no references to previously sampled items.
+func (s *Varopt[T]) ResetAndClear() {
+	s.Reset()
+	clear(s.L[:cap(s.L)])
+	clear(s.T[:cap(s.T)])
+	clear(s.X[:cap(s.X)])
+	clear(s.top[:cap(s.top)])
+	clear(s.sticky[:cap(s.sticky)])
+	s.first = internal.Vsample[T]{}
+	s.last = internal.Vsample[T]{}
+}
+
+// ResetWithCapacity returns the sampler to its initial state with a
+// new capacity, maintaining its random number source.  Backing
+// storage is reallocated only if capacity exceeds its current size.
+// ResetWithCapacity panics if capacity is not positive, leaving the
+// sampler unchanged.
+func (s *Varopt[T]) ResetWithCapacity(capacity int) {
+	if capacity <= 0 {
+		panic(fmt.Sprintf("varopt: %v (capacity %d)", ErrInvalidCapacity, capacity))
+	}
+	s.Reset()
+	s.capacity = capacity
+	s.presize(capacity)
 }
 
 // CopyFrom copies the fields of `from` into this Varopt[T].
 func (s *Varopt[T]) CopyFrom(from *Varopt[T]) {
+	s.mustNotBeSealed()
 	// Copy non-slice fields
 	cpy := *from
 	// Keep existing slices, reset
 	cpy.L = s.L[:0]
 	cpy.T = s.T[:0]
 	cpy.X = s.X[:0]
+	cpy.top = s.top[:0]
+	cpy.sticky = s.sticky[:0]
 	// Append to existing slices
 	cpy.L = append(cpy.L, from.L...)
 	cpy.T = append(cpy.T, from.T...)
 	cpy.X = append(cpy.X, from.X...)
+	cpy.top = append(cpy.top, from.top...)
+	cpy.sticky = append(cpy.sticky, from.sticky...)
 	// Assign back to `s`
 	*s = cpy
 }
 
+// Clone returns a deep copy of the sampler.  The copy shares the
+// random number generator; use CloneWithRand() to give it its own.
+func (s *Varopt[T]) Clone() *Varopt[T] {
+	c := &Varopt[T]{}
+	c.CopyFrom(s)
+	return c
+}
+
+// CloneWithRand returns a deep copy of the sampler that uses the given
+// random number generator.
+func (s *Varopt[T]) CloneWithRand(rnd Rand) *Varopt[T] {
+	c := s.Clone()
+	c.rnd = rnd
+	return c
+}
+
+// Equal reports whether two samplers hold the same sample: they
+// must agree on capacity, threshold, total count and total weight,
+// and hold the same multiset of items with their original weights,
+// compared using itemEq.  The order of items is not considered, as it
+// is an implementation detail.
+func (s *Varopt[T]) Equal(other *Varopt[T], itemEq func(a, b T) bool) bool {
+	if s.capacity != other.capacity ||
+		s.tau != other.tau ||
+		s.totalCount != other.totalCount ||
+		s.TotalWeight() != other.TotalWeight() ||
+		s.Size() != other.Size() {
+		return false
+	}
+	samples := func(v *Varopt[T]) []internal.Vsample[T] {
+		r := make([]internal.Vsample[T], 0, v.Size())
+		r = append(r, v.L...)
+		r = append(r, v.T...)
+		for i := 0; i < v.numPinned(); i++ {
+			r = append(r, v.pinned(i))
+		}
+		return r
+	}
+	mine, theirs := samples(s), samples(other)
+	used := make([]bool, len(theirs))
+outer:
+	for _, a := range mine {
+		for j, b := range theirs {
+			if !used[j] && a.Weight == b.Weight && itemEq(a.Sample, b.Sample) {
+				used[j] = true
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
 // Add considers a new observation for the sample with given weight.
 // If there is an item ejected from the sample as a result, the item
 // is returned to allow re-use of memory.
 //
 // An error will be returned if the weight is either negative or NaN.
 func (s *Varopt[T]) Add(item T, weight float64) (T, error) {
-	var zero T
+	if s.sealed {
+		var zero T
+		return zero, ErrSealed
+	}
+	if !validWeight(weight) {
+		var zero T
+		return zero, ErrInvalidWeight
+	}
+
+	eject, _ := s.observe(item, weight, nil)
+	return eject, nil
+}
+
+// AddAt is like Add() for a sampler with forward decay (see
+// WithForwardDecay()), for an observation made at time t.  The item is
+// added with weight × g(t - landmark), so that items observed earlier
+// are progressively less likely to remain in the sample, without
+// reweighting the items already there.  Adjusted weights and
+// TotalWeight() are in the resulting units; multiply them by
+// DecayScale(now) to obtain decayed weights as of now.
+//
+// An error is returned if the sampler was constructed without forward
+// decay or the scaled weight is invalid.
+func (s *Varopt[T]) AddAt(item T, weight float64, t time.Time) (T, error) {
+	if s.growth == nil {
+		var zero T
+		return zero, ErrNoDecay
+	}
+	return s.Add(item, weight*s.growth(t.Sub(s.landmark)))
+}
+
+// DecayScale returns 1 / g(now - landmark), the factor that converts
+// weights added by AddAt() into forward-decayed weights as of now.  It
+// returns 1 for a sampler without forward decay.
+func (s *Varopt[T]) DecayScale(now time.Time) float64 {
+	if s.growth == nil {
+		return 1
+	}
+	return 1 / s.growth(now.Sub(s.landmark))
+}
+
+// AddInfo describes the outcome of AddReport().
+type AddInfo struct {
+	// Ejected is true if an item was ejected.
+	Ejected bool
+	// EjectProbability is the probability with which the ejected
+	// item was chosen for ejection, or 0 if none was ejected.
+	EjectProbability float64
+	// SelfEjected is true if the ejected item is the one added.
+	SelfEjected bool
+	// SelfEjectProbability is the probability that the item added
+	// would be ejected.
+	SelfEjectProbability float64
+	// Heavy is true if the item added is held with its exact
+	// weight, in L or pinned, and false if it was ejected or
+	// landed in T with adjusted weight Tau.
+	Heavy bool
+	// Tau is the threshold after the addition.
+	Tau float64
+}
+
+// AddReport is like Add(), and also describes the outcome of the
+// addition for diagnostic purposes.
+//
+// When the item added is heavy and exactly ties the weight of another
+// item that is moved into T in the same step, the two are not
+// distinguished and the report may describe the other.
+func (s *Varopt[T]) AddReport(item T, weight float64) (T, AddInfo, error) {
+	var info AddInfo
+	if s.sealed {
+		var zero T
+		return zero, info, ErrSealed
+	}
+	if !validWeight(weight) {
+		var zero T
+		return zero, info, ErrInvalidWeight
+	}
+
+	eject, _ := s.observe(item, weight, &info)
+	return eject, info, nil
+}
+
+// AddBatch considers a sequence of observations, equivalent to calling
+// Add() for each item and weight in turn, and returns the items
+// ejected as a result (possibly fewer than the number of inputs).
+//
+// The batch is validated before any state changes: if the lengths
+// differ or any weight is invalid, an error is returned and none of
+// the items are added.
+func (s *Varopt[T]) AddBatch(items []T, weights []float64) ([]T, error) {
+	if s.sealed {
+		return nil, ErrSealed
+	}
+	if len(items) != len(weights) {
+		return nil, ErrLengthMismatch
+	}
+	for _, w := range weights {
+		if !validWeight(w) {
+			return nil, ErrInvalidWeight
+		}
+	}
+	var ejected []T
+	for i, item := range items {
+		if eject, ok := s.observe(item, weights[i], nil); ok {
+			ejected = append(ejected, eject)
+		}
+	}
+	return ejected, nil
+}
+
+// observe adds a valid observation, returning the ejected item, if
+// any.  If info is not nil it is filled in as described by AddInfo.
+func (s *Varopt[T]) observe(item T, weight float64, info *AddInfo) (T, bool) {
 	individual := internal.Vsample[T]{
 		Sample: item,
 		Weight: weight,
 	}
 
-	if weight <= 0 || math.IsNaN(weight) || math.IsInf(weight, 1) {
-		return zero, ErrInvalidWeight
+	if s.hash != nil {
+		s.hashSrc.reset(s.hashSeed, s.hash(item), uint64(s.totalCount))
 	}
 
 	s.totalCount++
-	s.totalWeight += weight
+	s.addWeight(weight)
 
-	if s.Size() < s.capacity {
-		s.L.Push(individual)
-		return zero, nil
+	if s.topK > 0 && (len(s.top) < s.topK || weight > s.top[0].Weight) {
+		s.top.Push(individual)
+		if len(s.top) > s.topK {
+			s.top.Pop()
+		}
+	}
+
+	if s.value != nil {
+		if v := s.value(item); !(v >= s.valueLo && v <= s.valueHi) {
+			if s.clamp == nil {
+				s.ejected(individual)
+				if info != nil {
+					*info = AddInfo{
+						Ejected:              true,
+						EjectProbability:     1,
+						SelfEjected:          true,
+						SelfEjectProbability: 1,
+						Tau:                  s.tau,
+					}
+				}
+				return item, true
+			}
+			item = s.clamp(item, s.valueLo, s.valueHi)
+			individual.Sample = item
+		}
+	}
+
+	if s.keepFirst && !s.hasFirst {
+		s.first, s.hasFirst = individual, true
+		if info != nil {
+			*info = AddInfo{Heavy: true, Tau: s.tau}
+		}
+		var zero T
+		return zero, false
+	}
+	if s.keepLast {
+		prev, had := s.last, s.hasLast
+		s.last, s.hasLast = individual, true
+		if !had {
+			if info != nil {
+				*info = AddInfo{Heavy: true, Tau: s.tau}
+			}
+			var zero T
+			return zero, false
+		}
+		// The item added is pinned; the report describes the
+		// ejection, if any, caused by adding the previous one.
+		eject, ok := s.add(prev, info)
+		if info != nil {
+			info.SelfEjected = false
+			info.SelfEjectProbability = 0
+			info.Heavy = true
+		}
+		return eject, ok
+	}
+
+	return s.add(individual, info)
+}
+
+// WarmStart loads an existing weighted sample, such as the items and
+// adjusted weights of an earlier sampler, into an empty sampler.
+// Subsequent calls to Add() continue as though the items had been
+// observed: TotalCount() and TotalWeight() include them and, if there
+// are more items than Capacity(), the excess is ejected and Tau() set
+// as by Add().  Pinning, value bounds and top-weight tracking do not
+// apply to warm-start items.
+//
+// Inputs are validated before any state changes: an error is returned
+// if the sampler has observations, the lengths differ, or any weight
+// is invalid.
+func (s *Varopt[T]) WarmStart(items []T, weights []float64) error {
+	if s.sealed {
+		return ErrSealed
+	}
+	if s.totalCount != 0 || s.Size() != 0 {
+		return ErrNotEmpty
+	}
+	if len(items) != len(weights) {
+		return ErrLengthMismatch
+	}
+	for _, w := range weights {
+		if !validWeight(w) {
+			return ErrInvalidWeight
+		}
+	}
+	for i, item := range items {
+		s.totalCount++
+		s.addWeight(weights[i])
+		s.add(internal.Vsample[T]{
+			Sample: item,
+			Weight: weights[i],
+		}, nil)
+	}
+	return nil
+}
+
+// Merge folds the sample of other, which must have the same capacity,
+// into this sampler by adding each of its items with its adjusted
+// weight.  The result is a valid VarOpt sample of the combined
+// streams, and TotalCount() and TotalWeight() become the sums of both
+// samplers' totals.  Merged items report their adjusted weight from
+// other as their original weight.  Other is not modified; merging a
+// sampler into itself returns ErrSelfMerge.
+func (s *Varopt[T]) Merge(other *Varopt[T]) error {
+	if s.sealed {
+		return ErrSealed
+	}
+	if other == s {
+		return ErrSelfMerge
+	}
+	if s.capacity != other.capacity {
+		return ErrCapacityMismatch
+	}
+	if s.rnd == nil {
+		return ErrNilRand
+	}
+	s.merge(other)
+	return nil
+}
+
+// MergeAll merges each of sources into dst, as dst.Merge() does, in a
+// single pass that reuses dst's reservoir.  This is the reduce step
+// for sharded sampling: dst may be a fresh sampler or a shard itself.
+// Every source must have the same capacity as dst, and none may be
+// dst itself (ErrSelfMerge); sources are checked before any is merged,
+// so on error dst is not modified.
+func MergeAll[T any](dst *Varopt[T], sources ...*Varopt[T]) error {
+	if dst.sealed {
+		return ErrSealed
+	}
+	if dst.rnd == nil {
+		return ErrNilRand
+	}
+	for _, src := range sources {
+		if src == dst {
+			return ErrSelfMerge
+		}
+		if src.capacity != dst.capacity {
+			return ErrCapacityMismatch
+		}
+	}
+	for _, src := range sources {
+		dst.merge(src)
+	}
+	return nil
+}
+
+// merge adds the sample and totals of other, which has been checked
+// to have the same capacity.
+func (s *Varopt[T]) merge(other *Varopt[T]) {
+	for i := 0; i < other.Size(); i++ {
+		item, weight := other.Get(i)
+		s.add(internal.Vsample[T]{
+			Sample: item,
+			Weight: weight,
+		}, nil)
+	}
+	s.totalCount += other.totalCount
+	s.addWeight(other.totalWeight)
+	s.addWeight(other.totalComp)
+}
+
+// add inserts an item into the reservoir, returning the ejected item
+// if the reservoir was full.  If info is not nil it is filled in as
+// described by AddInfo.
+func (s *Varopt[T]) add(individual internal.Vsample[T], info *AddInfo) (T, bool) {
+	var zero T
+
+	if len(s.L)+len(s.T) < s.capacity {
+		if s.tau == 0 {
+			// Until the first ejection L is kept in
+			// insertion order; see GetInsertionOrder().
+			s.L = append(s.L, individual)
+		} else {
+			s.L.Push(individual)
+		}
+		s.maxHeavy = max(s.maxHeavy, len(s.L))
+		if info != nil {
+			*info = AddInfo{Heavy: true, Tau: s.tau}
+		}
+		return zero, false
+	}
+
+	if s.tau == 0 {
+		s.L.Init()
 	}
 
 	// the X <- {} step from the paper is not done here,
@@ -115,20 +701,150 @@ func (s *Varopt[T]) Add(item T, weight float64) (T, error) {
 
 	W := s.tau * float64(len(s.T))
 
-	if weight > s.tau {
-		s.L.Push(individual)
-	} else {
+	light := individual.Weight <= s.tau
+	if light {
 		s.X = append(s.X, individual)
-		W += weight
+		W += individual.Weight
+	} else {
+		s.L.Push(individual)
+		s.maxHeavy = max(s.maxHeavy, len(s.L))
+	}
+
+	if info != nil {
+		return s.reduceReport(W, individual.Weight, info), true
 	}
+	return s.reduce(W), true
+}
+
+// shrink ejects one item from the reservoir, leaving a valid VarOpt
+// sample of one fewer item, and returns it.  The reservoir must not
+// be empty.
+func (s *Varopt[T]) shrink() T {
+	if len(s.L)+len(s.T) == 1 {
+		var eject internal.Vsample[T]
+		if len(s.L) == 1 {
+			eject = s.L[0]
+		} else {
+			eject = s.T[0]
+		}
+		s.L = s.L[:0]
+		s.T = s.T[:0]
+		s.tau = 0
+		s.ejected(eject)
+		return eject.Sample
+	}
+	if s.tau == 0 {
+		s.L.Init()
+	}
+	return s.reduce(s.tau * float64(len(s.T)))
+}
+
+// reduce computes the new threshold and ejects one item from the
+// union of L, T and X, where W is the total weight of T and X.
+func (s *Varopt[T]) reduce(W float64) T {
+	s.raise(W)
+	eject, _ := s.eject()
+	return eject
+}
+
+// reduceReport is reduce() for an addition of the given weight,
+// filling in info.
+func (s *Varopt[T]) reduceReport(W, weight float64, info *AddInfo) T {
+	s.raise(W)
+
+	// The item added is in X if it is light, or if it was moved
+	// there from L.  A light item's weight is less than that of any
+	// item moved from L, and items of equal weight move from L
+	// together, so only another heavy item can share its weight.
+	self := -1
+	for i, x := range s.X {
+		if x.Weight == weight {
+			self = i
+			break
+		}
+	}
+	probX, probT := s.ejectProbabilities()
 
-	for len(s.L) > 0 && W >= float64(len(s.T)+len(s.X)-1)*s.L[0].Weight {
+	eject, xi := s.eject()
+
+	*info = AddInfo{
+		Ejected:          true,
+		EjectProbability: probT,
+		SelfEjected:      xi >= 0 && xi == self,
+		Heavy:            self < 0,
+		Tau:              s.tau,
+	}
+	if xi >= 0 {
+		info.EjectProbability = probX[xi]
+	}
+	if self >= 0 {
+		info.SelfEjectProbability = probX[self]
+	}
+	return eject
+}
+
+// raise moves items from L to X until the smallest remaining in L is
+// heavier than the new threshold, then sets the threshold, where W is
+// the total weight of T and X.
+func (s *Varopt[T]) raise(W float64) {
+	for {
+		least, ok := s.L.Peek()
+		if !ok || W < float64(len(s.T)+len(s.X)-1)*least.Weight {
+			break
+		}
 		h := s.L.Pop()
 		s.X = append(s.X, h)
 		W += h.Weight
 	}
 
 	s.tau = W / float64(len(s.T)+len(s.X)-1)
+
+	if s.less != nil {
+		slices.SortFunc(s.X, s.compare)
+	}
+}
+
+// compare orders samples by weight, then by the order given to
+// SetDeterministic().
+func (s *Varopt[T]) compare(a, b internal.Vsample[T]) int {
+	switch {
+	case a.Weight != b.Weight:
+		return cmp.Compare(a.Weight, b.Weight)
+	case s.less(a.Sample, b.Sample):
+		return -1
+	case s.less(b.Sample, a.Sample):
+		return 1
+	}
+	return 0
+}
+
+// ejectProbabilities returns the probability that eject() ejects
+// each item of X, and each item of T.  These follow the selection
+// made by eject(): a uniform variate r selects the first k for which
+// r is less than the sum of 1-X[i].Weight/tau over i <= k, and X[k]
+// is ejected, with probability 1-X[k].Weight/tau.  If there is no
+// such k, an item of T is ejected: uniformly, or the last one if
+// SetStable() is enabled, whose probability is returned.
+func (s *Varopt[T]) ejectProbabilities() ([]float64, float64) {
+	probX := make([]float64, len(s.X))
+	sum := 0.0
+	for k := range s.X {
+		lo := math.Min(sum, 1)
+		sum += 1 - s.X[k].Weight/s.tau
+		p := math.Min(sum, 1) - lo
+		probX[k] = p
+	}
+	probT := 1 - math.Min(sum, 1)
+	if !s.stable && len(s.T) > 0 {
+		probT /= float64(len(s.T))
+	}
+	return probX, probT
+}
+
+// eject ejects one item from the union of T and X, using the
+// threshold set by raise(), and returns it along with its index in X
+// or -1 if it was taken from T.
+func (s *Varopt[T]) eject() (T, int) {
 	r := s.uniform()
 	d := 0
 
@@ -137,26 +853,108 @@ func (s *Varopt[T]) Add(item T, weight float64) (T, error) {
 		r -= (1 - wxd/s.tau)
 		d++
 	}
-	var eject T
+	var eject internal.Vsample[T]
+	xi := -1
 	if r < 0 {
-		if d < len(s.X) {
-			s.X[d], s.X[len(s.X)-1] = s.X[len(s.X)-1], s.X[d]
-		}
-		eject = s.X[len(s.X)-1].Sample
+		// X[d-1] took r below zero.
+		xi = d - 1
+		s.X[xi], s.X[len(s.X)-1] = s.X[len(s.X)-1], s.X[xi]
+		eject = s.X[len(s.X)-1]
 		s.X = s.X[:len(s.X)-1]
 	} else {
-		ti := s.rnd.Intn(len(s.T))
+		if s.less != nil {
+			slices.SortFunc(s.T, s.compare)
+		}
+		ti := len(s.T) - 1
+		if !s.stable {
+			if s.audit {
+				s.intDraws++
+			}
+			ti = s.rnd.Intn(len(s.T))
+		}
 		s.T[ti], s.T[len(s.T)-1] = s.T[len(s.T)-1], s.T[ti]
-		eject = s.T[len(s.T)-1].Sample
+		eject = s.T[len(s.T)-1]
 		s.T = s.T[:len(s.T)-1]
 	}
 	s.T = append(s.T, s.X...)
 	s.X = s.X[:0]
-	return eject, nil
+	s.ejected(eject)
+	return eject.Sample, xi
+}
+
+// ejected calls the OnEject() callback, if any.
+func (s *Varopt[T]) ejected(v internal.Vsample[T]) {
+	if s.onEject != nil {
+		s.onEject(v.Sample, v.Weight)
+	}
+}
+
+// Resize changes the capacity of the reservoir.  Growing takes effect
+// as subsequent calls to Add() fill the larger reservoir: items
+// already in the sample keep their adjusted weights, new items are
+// retained with their exact weight until the reservoir is full again,
+// and from then on ejection treats the light items as having weight
+// Tau() as it always does.  Shrinking down-samples the current
+// contents to the new capacity using the VarOpt ejection procedure,
+// one item at a time.  Either way the result remains a valid VarOpt
+// sample of the stream.
+func (s *Varopt[T]) Resize(capacity int) error {
+	if s.sealed {
+		return ErrSealed
+	}
+	if capacity <= 0 {
+		return ErrInvalidCapacity
+	}
+	for len(s.L)+len(s.T) > capacity {
+		s.shrink()
+	}
+	s.capacity = capacity
+	// The high-water mark refers to the current capacity.
+	s.maxHeavy = len(s.L)
+	return nil
+}
+
+// WouldAdmit reports whether an item with the given weight has a
+// nonzero probability of being retained by Add() in the current
+// state.  Items are certain to be retained while the reservoir is not
+// full or when their weight exceeds Tau(); any other valid weight is
+// retained with positive probability, so this returns false only for
+// weights that Add() would reject.  Value bounds (see
+// SetValueBounds) are not considered.
+func (s *Varopt[T]) WouldAdmit(weight float64) bool {
+	return validWeight(weight)
+}
+
+// ProjectedAdjustedWeight returns the adjusted weight an item with
+// the given weight would carry if it were admitted to the sample now:
+// its own weight if the reservoir is not full or the weight exceeds
+// Tau(), otherwise Tau().  Adding an item to a full reservoir raises
+// the threshold, so a light item's actual adjusted weight will be
+// slightly larger than projected.
+func (s *Varopt[T]) ProjectedAdjustedWeight(weight float64) float64 {
+	if len(s.L)+len(s.T) < s.capacity || weight > s.tau {
+		return weight
+	}
+	return s.tau
+}
+
+func validWeight(weight float64) bool {
+	return weight > 0 && !math.IsNaN(weight) && !math.IsInf(weight, 1)
+}
+
+// fromRand converts a *rand.Rand to a Rand, keeping nil as nil.
+func fromRand(rnd *rand.Rand) Rand {
+	if rnd == nil {
+		return nil
+	}
+	return rnd
 }
 
 func (s *Varopt[T]) uniform() float64 {
 	for {
+		if s.audit {
+			s.floatDraws++
+		}
 		r := s.rnd.Float64()
 		if r != 0.0 {
 			return r
@@ -166,24 +964,272 @@ func (s *Varopt[T]) uniform() float64 {
 
 // Get() returns the i'th sample and its adjusted weight. To obtain
 // the sample's original weight (i.e. what was passed to Add), use
-// GetOriginalWeight(i).
+// GetOriginalWeight(i).  Get panics if i is not in [0, Size()); see
+// TryGet().
 func (s *Varopt[T]) Get(i int) (T, float64) {
+	s.checkIndex(i)
 	if i < len(s.L) {
 		return s.L[i].Sample, s.L[i].Weight
 	}
+	if i < len(s.L)+len(s.T) {
+		return s.T[i-len(s.L)].Sample, s.tau
+	}
 
-	return s.T[i-len(s.L)].Sample, s.tau
+	p := s.pinned(i - len(s.L) - len(s.T))
+	return p.Sample, p.Weight
+}
+
+// GetWeighted returns the i'th sample with its adjusted weight, as
+// Get() does, its original weight, as GetOriginalWeight() does, and
+// its inclusion probability, as InclusionProbability() does.  Like
+// Get(), it panics if i is out of range.
+func (s *Varopt[T]) GetWeighted(i int) Weighted[T] {
+	s.checkIndex(i)
+	var v internal.Vsample[T]
+	adjusted, prob := 0.0, 1.0
+	switch {
+	case i < len(s.L):
+		v = s.L[i]
+		adjusted = v.Weight
+	case i < len(s.L)+len(s.T):
+		v = s.T[i-len(s.L)]
+		adjusted = s.tau
+		prob = math.Min(1, v.Weight/s.tau)
+	default:
+		v = s.pinned(i - len(s.L) - len(s.T))
+		adjusted = v.Weight
+	}
+	return Weighted[T]{
+		Item:                 v.Sample,
+		AdjustedWeight:       adjusted,
+		OriginalWeight:       v.Weight,
+		InclusionProbability: prob,
+	}
+}
+
+// TryGet is like Get(), but returns false instead of panicking when i
+// is out of range.
+func (s *Varopt[T]) TryGet(i int) (T, float64, bool) {
+	if i < 0 || i >= s.Size() {
+		var zero T
+		return zero, 0, false
+	}
+	item, weight := s.Get(i)
+	return item, weight, true
+}
+
+// checkIndex panics with a descriptive message if i is not a valid
+// index into the sample.
+func (s *Varopt[T]) checkIndex(i int) {
+	if i < 0 || i >= s.Size() {
+		panic(fmt.Sprintf("varopt: index %d out of range with Size() %d", i, s.Size()))
+	}
+}
+
+// All returns an iterator over the samples and their adjusted
+// weights, in Get() order.
+func (s *Varopt[T]) All() iter.Seq2[T, float64] {
+	return func(yield func(T, float64) bool) {
+		for i := 0; i < s.Size(); i++ {
+			if !yield(s.Get(i)) {
+				return
+			}
+		}
+	}
+}
+
+// AllOriginal returns an iterator over the samples and their original
+// weights, in Get() order.
+func (s *Varopt[T]) AllOriginal() iter.Seq2[T, float64] {
+	return func(yield func(T, float64) bool) {
+		for i := 0; i < s.Size(); i++ {
+			item, _ := s.Get(i)
+			if !yield(item, s.GetOriginalWeight(i)) {
+				return
+			}
+		}
+	}
+}
+
+// View calls fn for each sample in the order of Get(), with its index,
+// adjusted weight and original weight.  Unlike AppendTo() it neither
+// copies the sample nor allocates.  Fn must not modify the sampler.
+func (s *Varopt[T]) View(fn func(i int, item T, adjusted, original float64)) {
+	for i := 0; i < s.Size(); i++ {
+		w := s.GetWeighted(i)
+		fn(i, w.Item, w.AdjustedWeight, w.OriginalWeight)
+	}
+}
+
+// AppendTo appends every item in the sample, with its adjusted and
+// original weights, to dst in the order of Get() and returns the
+// extended slice.  Passing a reused buffer, as in s.AppendTo(buf[:0]),
+// avoids allocation once it has grown to Size().
+func (s *Varopt[T]) AppendTo(dst []Weighted[T]) []Weighted[T] {
+	for i := 0; i < s.Size(); i++ {
+		dst = append(dst, s.GetWeighted(i))
+	}
+	return dst
+}
+
+// SampleUniform draws n items from the sample with replacement, each
+// with probability proportional to its adjusted weight, so that the
+// result approximates an unweighted sample of the observations.  It
+// uses the sampler's random number generator, and returns nil if n is
+// not positive or the sample is empty.
+func (s *Varopt[T]) SampleUniform(n int) []T {
+	if n <= 0 || s.Size() == 0 {
+		return nil
+	}
+	cum := s.cumulativeWeights()
+	out := make([]T, n)
+	for j := range out {
+		out[j], _ = s.Get(s.drawIndex(cum))
+	}
+	return out
+}
+
+// cumulativeWeights returns the running sums of the adjusted weights
+// in Get() order.
+func (s *Varopt[T]) cumulativeWeights() []float64 {
+	cum := make([]float64, s.Size())
+	sum := 0.0
+	for i := range cum {
+		_, w := s.Get(i)
+		sum += w
+		cum[i] = sum
+	}
+	return cum
+}
+
+// drawIndex returns a random index into the sample with probability
+// proportional to adjusted weight, given cumulativeWeights().
+func (s *Varopt[T]) drawIndex(cum []float64) int {
+	i := sort.SearchFloat64s(cum, s.uniform()*cum[len(cum)-1])
+	return min(i, len(cum)-1)
+}
+
+// GetInsertionOrder returns the i'th sample in the order it was
+// passed to Add(), along with its adjusted weight.  Arrival order is
+// only tracked until the reservoir ejects its first item; after that
+// ok is false.  Only the reservoir items are ordered by arrival; as in
+// Get(), they are followed by the pinned first and last items and then
+// the sticky items.  Like Get(), it panics if i is not in [0, Size()).
+func (s *Varopt[T]) GetInsertionOrder(i int) (item T, weight float64, ok bool) {
+	s.checkIndex(i)
+	if s.tau != 0 {
+		return item, 0, false
+	}
+	if i < len(s.L) {
+		return s.L[i].Sample, s.L[i].Weight, true
+	}
+	p := s.pinned(i - len(s.L))
+	return p.Sample, p.Weight, true
 }
 
 // GetOriginalWeight returns the original input weight of the sample
 // item that was passed to Add().  This can be useful for computing a
-// frequency from the adjusted sample weight.
+// frequency from the adjusted sample weight.  Like Get(), it panics
+// if i is out of range.
 func (s *Varopt[T]) GetOriginalWeight(i int) float64 {
+	s.checkIndex(i)
 	if i < len(s.L) {
 		return s.L[i].Weight
 	}
+	if i < len(s.L)+len(s.T) {
+		return s.T[i-len(s.L)].Weight
+	}
+
+	return s.pinned(i - len(s.L) - len(s.T)).Weight
+}
+
+// InclusionProbability returns the probability with which the i'th
+// sample was included: 1 for large-weight (and pinned) items and
+// GetOriginalWeight(i) / Tau() for the others.  The adjusted weight
+// returned by Get(i) is the original weight divided by this
+// probability.  Like Get(), it panics if i is out of range.
+func (s *Varopt[T]) InclusionProbability(i int) float64 {
+	s.checkIndex(i)
+	if i < len(s.L) || i >= len(s.L)+len(s.T) {
+		return 1
+	}
+	return math.Min(1, s.T[i-len(s.L)].Weight/s.tau)
+}
+
+// MinLargeWeight returns the smallest weight among the large-weight
+// items, those held with their exact weight, excluding pinned items.
+// It returns false if there are none.  Before the first ejection all
+// items are large-weight.
+func (s *Varopt[T]) MinLargeWeight() (float64, bool) {
+	if s.tau != 0 {
+		least, ok := s.L.Peek()
+		return least.Weight, ok
+	}
+	// L is in insertion order; see GetInsertionOrder().
+	if len(s.L) == 0 {
+		return 0, false
+	}
+	least := s.L[0].Weight
+	for _, v := range s.L[1:] {
+		least = math.Min(least, v.Weight)
+	}
+	return least, true
+}
 
-	return s.T[i-len(s.L)].Weight
+// NumHeavy returns the number of heavy items in the sample, which
+// carry their exact original weight: those with weight above Tau(),
+// every item before the reservoir first fills, and pinned and sticky
+// items (see SetKeepFirst and AddSticky).  NumHeavy()+NumLight()
+// equals Size().
+func (s *Varopt[T]) NumHeavy() int {
+	return len(s.L) + s.numPinned()
+}
+
+// NumLight returns the number of light items in the sample, which
+// share the adjusted weight Tau().  A sample dominated by heavy items
+// suggests that the capacity is small relative to the weight skew.
+func (s *Varopt[T]) NumLight() int {
+	return len(s.T)
+}
+
+// WeightRange returns the smallest and largest original weights of
+// the items in the sample, or zeros if it is empty.
+func (s *Varopt[T]) WeightRange() (min, max float64) {
+	n := s.Size()
+	if n == 0 {
+		return 0, 0
+	}
+	min, max = math.Inf(1), math.Inf(-1)
+	for i := 0; i < n; i++ {
+		w := s.GetOriginalWeight(i)
+		min = math.Min(min, w)
+		max = math.Max(max, w)
+	}
+	return min, max
+}
+
+// MaxHeavyItems returns the largest number of large-weight items held
+// at once since the sampler was constructed, Reset(), resized by
+// Resize() or restored by GobDecode() or FromProto(), including the
+// one item by which the heap transiently exceeds the reservoir while
+// an item is being added.  It never exceeds Capacity()+1.
+func (s *Varopt[T]) MaxHeavyItems() int {
+	return s.maxHeavy
+}
+
+// MemStats returns the number of heavy and light items held, as
+// NumHeavy() and NumLight() do, and the capacity of the temporary
+// buffer used by Add().  Each item is stored alongside a float64
+// weight, so the sampler's backing storage occupies approximately
+//
+//	(heavy + light + tempCap) × (unsafe.Sizeof(item) + 8)
+//
+// bytes, plus padding when T is not 8-byte aligned and whatever
+// memory the items themselves refer to.  Because buffers are sized
+// for the capacity when the sampler is constructed, substituting
+// Capacity()+1 for heavy + light gives the steady-state footprint.
+func (s *Varopt[T]) MemStats() (heavy, light, tempCap int) {
+	return s.NumHeavy(), s.NumLight(), cap(s.X)
 }
 
 // Capacity returns the size of the reservoir.  This is the maximum
@@ -193,24 +1239,410 @@ func (s *Varopt[T]) Capacity() int {
 }
 
 // Size returns the current number of items in the sample.  If the
-// reservoir is full, this returns Capacity().
+// reservoir is full, this returns Capacity(), plus the number of
+// pinned items if SetKeepFirst() or SetKeepLast() are in use and of
+// items added by AddSticky().
 func (s *Varopt[T]) Size() int {
-	return len(s.L) + len(s.T)
+	return len(s.L) + len(s.T) + s.numPinned()
 }
 
 // TotalWeight returns the sum of weights that were passed to Add().
 func (s *Varopt[T]) TotalWeight() float64 {
-	return s.totalWeight
+	return s.totalWeight + s.totalComp
 }
 
-// TotalCount returns the number of calls to Add().
+// TotalCount returns the number of calls to Add().  On platforms
+// where int is 32 bits the result wraps after 2^31 calls; use
+// TotalCount64() for long-running samplers.
 func (s *Varopt[T]) TotalCount() int {
+	return int(s.totalCount)
+}
+
+// TotalCount64 returns the number of calls to Add() as an int64.
+func (s *Varopt[T]) TotalCount64() int64 {
 	return s.totalCount
 }
 
+// SetKeepFirst controls whether the earliest observation is pinned in
+// the sample regardless of its weight.  SetKeepLast similarly pins
+// the most recent observation; each call to Add() replaces the pinned
+// item, and the previously pinned item is then considered for the
+// reservoir as usual.  Both should be set before the first call to
+// Add() or after Reset(); disabling either does not release an item
+// that is already pinned.
+//
+// Pinned items are held in addition to Capacity() and are indexed
+// after the reservoir's items by Get().  They are certainties: their
+// adjusted weight equals their original weight, so estimates remain
+// unbiased.
+func (s *Varopt[T]) SetKeepFirst(keep bool) {
+	s.keepFirst = keep
+}
+
+// SetKeepLast controls whether the most recent observation is pinned
+// in the sample.  See SetKeepFirst().
+func (s *Varopt[T]) SetKeepLast(keep bool) {
+	s.keepLast = keep
+}
+
+// numPinned returns the number of items held outside the reservoir:
+// the pinned first and last items, then the sticky items.
+func (s *Varopt[T]) numPinned() int {
+	n := len(s.sticky)
+	if s.hasFirst {
+		n++
+	}
+	if s.hasLast {
+		n++
+	}
+	return n
+}
+
+// pinned returns the i'th item held outside the reservoir.
+func (s *Varopt[T]) pinned(i int) internal.Vsample[T] {
+	if s.hasFirst {
+		if i == 0 {
+			return s.first
+		}
+		i--
+	}
+	if s.hasLast {
+		if i == 0 {
+			return s.last
+		}
+		i--
+	}
+	return s.sticky[i]
+}
+
+// AddSticky adds an observation that is never ejected: it is held
+// outside the reservoir, not counted toward Capacity(), and indexed by
+// Get() after the reservoir's items and any pinned items (see
+// SetKeepFirst).  Like a pinned item, its adjusted weight equals its
+// original weight.  It contributes to TotalCount() and TotalWeight()
+// but not to TopWeights(), and it is removed only by Reset() and its
+// variants.
+//
+// Sticky items are chosen by the caller rather than by the sampling
+// design, so they are not a random sample: their inclusion
+// probability is 1 by fiat, and analyses that treat the sample as
+// drawn by VarOpt must account for them separately.  Subset-sum
+// estimates remain unbiased because sticky items count with their
+// exact weight.
+//
+// An error will be returned if the weight is either negative or NaN.
+func (s *Varopt[T]) AddSticky(item T, weight float64) error {
+	if s.sealed {
+		return ErrSealed
+	}
+	if !validWeight(weight) {
+		return ErrInvalidWeight
+	}
+	s.totalCount++
+	s.addWeight(weight)
+	s.sticky = append(s.sticky, internal.Vsample[T]{
+		Sample: item,
+		Weight: weight,
+	})
+	return nil
+}
+
+// EnableTopWeights starts tracking the k highest-weight observations
+// passed to Add(), exactly and independently of the sample.  These
+// items may or may not be in the sample.  Observations made before
+// this call are not considered.  A k of zero disables tracking;
+// EnableTopWeights panics if k is negative.
+func (s *Varopt[T]) EnableTopWeights(k int) {
+	if k < 0 {
+		panic(fmt.Sprintf("varopt: negative top weights count %d", k))
+	}
+	s.topK = k
+	for len(s.top) > k {
+		s.top.Pop()
+	}
+}
+
+// TopWeights returns the highest-weight observations, with their
+// original weights, in decreasing weight order.
+func (s *Varopt[T]) TopWeights() []Sampled[T] {
+	top := make([]Sampled[T], len(s.top))
+	h := append(internal.SampleHeap[T](nil), s.top...)
+	for i := len(top) - 1; i >= 0; i-- {
+		v := h.Pop()
+		top[i] = Sampled[T]{
+			Item:   v.Sample,
+			Weight: v.Weight,
+		}
+	}
+	return top
+}
+
+// HeavyHitters returns the large-weight items, those whose weight
+// exceeds Tau() and which are held with their exact weight, in
+// decreasing weight order.  Pinned and sticky items are not included.
+// This is an approximate top-K of the weighted stream that needs no
+// additional state, unlike TopWeights(): an item with large weight is
+// only reported while it remains above the threshold, which rises as
+// the stream grows, and before the reservoir first fills every item
+// is reported.
+func (s *Varopt[T]) HeavyHitters() []Weighted[T] {
+	var heavy []Weighted[T]
+	for _, v := range s.L {
+		// After Resize() or Rescale(), L may hold items that
+		// are no heavier than the threshold.
+		if s.tau != 0 && v.Weight <= s.tau {
+			continue
+		}
+		heavy = append(heavy, Weighted[T]{
+			Item:                 v.Sample,
+			AdjustedWeight:       v.Weight,
+			OriginalWeight:       v.Weight,
+			InclusionProbability: 1,
+		})
+	}
+	slices.SortStableFunc(heavy, func(a, b Weighted[T]) int {
+		return cmp.Compare(b.OriginalWeight, a.OriginalWeight)
+	})
+	return heavy
+}
+
+// SetValueBounds restricts the sample to items whose value lies in
+// [lo, hi].  Items outside the bounds (or with a NaN value) are
+// counted in TotalCount() and TotalWeight() but not admitted to the
+// reservoir; Add() returns them as though ejected.  Passing a nil
+// value function removes the bounds.
+//
+// This protects estimates from corrupt inputs, but excluded items
+// are not represented in the sample, so subset sums estimated from
+// it are biased low by the weight of the excluded items.  The
+// difference between TotalWeight() and the sum of adjusted weights
+// estimates that excluded weight.
+func (s *Varopt[T]) SetValueBounds(value func(T) float64, lo, hi float64) {
+	s.value = value
+	s.valueLo = lo
+	s.valueHi = hi
+	s.clamp = nil
+}
+
+// SetValueClamp is like SetValueBounds(), but items whose value lies
+// outside [lo, hi] (or is NaN) are replaced by clamp(item, lo, hi),
+// which should return the item with its value brought within the
+// bounds, and admitted to the reservoir with their original weight.
+// TopWeights() records items before they are clamped.
+//
+// Unlike exclusion, clamping keeps every observation's weight
+// represented in the sample, so weight estimates remain unbiased,
+// but estimates of the value itself are biased toward the interior
+// of [lo, hi] by the clamped items.  Passing a nil value function
+// removes the bounds.
+func (s *Varopt[T]) SetValueClamp(value func(T) float64, lo, hi float64, clamp func(item T, lo, hi float64) T) {
+	s.SetValueBounds(value, lo, hi)
+	if value != nil {
+		s.clamp = clamp
+	}
+}
+
+// Rescale multiplies the adjusted weights of sampled items for which
+// pred returns true by factor, and adjusts TotalWeight() by the
+// resulting change in estimated total.  Light items that match are
+// converted to exact-weight items carrying their rescaled adjusted
+// weight, which GetOriginalWeight() subsequently reports.  Invalid
+// factors (see ErrInvalidWeight) are ignored.
+//
+// This is meant for correcting a weighting error discovered after
+// sampling.  Estimates from the rescaled sample are unbiased for the
+// reweighted population only if the correction applies uniformly to
+// every matching observation, including those no longer in the
+// sample; the inclusion probabilities were determined by the original
+// weights, so the variance is not optimal for the new weights.
+func (s *Varopt[T]) Rescale(factor float64, pred func(T) bool) {
+	s.mustNotBeSealed()
+	if !validWeight(factor) {
+		return
+	}
+	delta := 0.0
+	for i := range s.L {
+		if pred(s.L[i].Sample) {
+			delta += s.L[i].Weight * (factor - 1)
+			s.L[i].Weight *= factor
+		}
+	}
+	light := s.T[:0]
+	for _, v := range s.T {
+		if !pred(v.Sample) {
+			light = append(light, v)
+			continue
+		}
+		delta += s.tau * (factor - 1)
+		s.L = append(s.L, internal.Vsample[T]{
+			Sample: v.Sample,
+			Weight: s.tau * factor,
+		})
+	}
+	s.T = light
+	if s.hasFirst && pred(s.first.Sample) {
+		delta += s.first.Weight * (factor - 1)
+		s.first.Weight *= factor
+	}
+	if s.hasLast && pred(s.last.Sample) {
+		delta += s.last.Weight * (factor - 1)
+		s.last.Weight *= factor
+	}
+	for i := range s.sticky {
+		if pred(s.sticky[i].Sample) {
+			delta += s.sticky[i].Weight * (factor - 1)
+			s.sticky[i].Weight *= factor
+		}
+	}
+	if s.tau != 0 {
+		s.L.Init()
+	}
+	s.maxHeavy = max(s.maxHeavy, len(s.L))
+	s.addWeight(delta)
+}
+
+// SetStable controls snapshot stability mode.  Once the sampler has
+// decided to eject one of the light items already in the sample, it
+// normally chooses among them uniformly at random; in stable mode it
+// instead ejects the one that entered the light set most recently, so
+// that samples taken at intervals differ as little as possible.
+//
+// The total weight ejected is unchanged, so the sum of adjusted
+// weights still matches TotalWeight(), but light items that have been
+// in the sample longer are favored.  Subset sums over groups that are
+// correlated with arrival time are therefore biased in stable mode.
+func (s *Varopt[T]) SetStable(stable bool) {
+	s.stable = stable
+}
+
+// OnEject registers fn to be called with each item that leaves the
+// sample and its original weight, so that resources tied to sampled
+// items can be released.  It is called when Add() and related methods
+// eject an item, including an item just added or excluded by
+// SetValueBounds(), when Resize() shrinks the sample, and for every
+// item in the sample when it is emptied by Reset() and its variants.
+// Fn must not modify the sampler.  A nil fn removes the callback.
+func (s *Varopt[T]) OnEject(fn func(item T, originalWeight float64)) {
+	s.onEject = fn
+}
+
+// SetDeterministic makes the sample independent of the arrival order
+// of equal-weight items, given the same sequence of random numbers,
+// by ordering the candidates for ejection by weight and then by less
+// before choosing one.  Less must be a strict total order on the
+// items that may be sampled together.  In stable mode (see
+// SetStable) the light item ejected is the last in this order rather
+// than the newest.  A nil less restores the default, which avoids the
+// cost of sorting.
+//
+// In either mode, samplers given the same observations in the same
+// order and the same sequence of random numbers produce the same
+// sample.
+func (s *Varopt[T]) SetDeterministic(less func(a, b T) bool) {
+	s.less = less
+}
+
+// EnableRandAudit starts counting the random numbers drawn by the
+// sampler; see RandDrawCount().
+func (s *Varopt[T]) EnableRandAudit() {
+	s.audit = true
+}
+
+// RandDrawCount returns the number of random floats and integers
+// drawn since EnableRandAudit() was called.  No numbers are drawn
+// while the reservoir is filling.  Once it is full, each call to
+// Add() draws one float (more only in the vanishingly rare case that
+// it is exactly zero) and, when the ejected item is one of the light
+// items already in the sample, one integer to choose among them
+// (except in stable mode; see SetStable()).
+// Reset() does not affect the counts.
+func (s *Varopt[T]) RandDrawCount() (floats, ints int) {
+	return s.floatDraws, s.intDraws
+}
+
+// Expire removes one observation of the given original weight from
+// the totals, for use when an item leaves a sliding observation
+// window.  Only TotalCount() and TotalWeight() are affected; the
+// sample itself is not modified.
+//
+// This is an approximation: the expired item may or may not still be
+// in the sample, and if it is, it continues to contribute to
+// estimates until it is ejected by later calls to Add().  Invalid
+// weights (see ErrInvalidWeight) are ignored, as are calls that would
+// make the totals negative: when there are no observations or the
+// weight exceeds TotalWeight().
+func (s *Varopt[T]) Expire(originalWeight float64) {
+	s.mustNotBeSealed()
+	if !validWeight(originalWeight) || s.totalCount == 0 || originalWeight > s.TotalWeight() {
+		return
+	}
+	s.totalCount--
+	s.addWeight(-originalWeight)
+}
+
+// addWeight adds w to the total weight using Neumaier's compensated
+// summation, so that the accumulated rounding error does not grow
+// with the number of observations, even when mixing very large and
+// very small weights.
+func (s *Varopt[T]) addWeight(w float64) {
+	if s.naiveSum {
+		s.totalWeight += w
+		return
+	}
+	t := s.totalWeight + w
+	if math.Abs(s.totalWeight) >= math.Abs(w) {
+		s.totalComp += (s.totalWeight - t) + w
+	} else {
+		s.totalComp += (w - t) + s.totalWeight
+	}
+	s.totalWeight = t
+}
+
+// Seal makes the sampler read-only, so that an accidental update
+// after the sample has been consumed fails loudly.  Once sealed, the
+// methods that add observations or change the sample and return an
+// error, such as Add(), Merge() and Resize(), return ErrSealed, while
+// the others, such as Reset(), Rescale() and CopyFrom(), panic.  Reads
+// are unaffected, and Init() returns the sampler to a new, unsealed
+// state.
+func (s *Varopt[T]) Seal() {
+	s.sealed = true
+}
+
+// Sealed returns whether Seal() has been called.
+func (s *Varopt[T]) Sealed() bool {
+	return s.sealed
+}
+
+// mustNotBeSealed panics if the sampler is sealed.
+func (s *Varopt[T]) mustNotBeSealed() {
+	if s.sealed {
+		panic(fmt.Sprintf("varopt: %v", ErrSealed))
+	}
+}
+
 // Tau returns the current large-weight threshold.  Weights larger
 // than Tau() carry their exact weight in the sample.  See the VarOpt
 // paper for details.
 func (s *Varopt[T]) Tau() float64 {
 	return s.tau
 }
+
+// String summarizes the state of the sampler for debugging, without
+// enumerating the sample.
+func (s *Varopt[T]) String() string {
+	return fmt.Sprintf("Varopt{capacity: %d, size: %d, tau: %g, L: %d, T: %d, count: %d, weight: %g}",
+		s.capacity, s.Size(), s.tau, len(s.L), len(s.T), s.totalCount, s.TotalWeight())
+}
+
+// Dump writes String() followed by one line per sampled item, in Get()
+// order, giving the item as rendered by format with its original and
+// adjusted weights.  Write errors are ignored.
+func (s *Varopt[T]) Dump(w io.Writer, format func(T) string) {
+	fmt.Fprintln(w, s)
+	for i := 0; i < s.Size(); i++ {
+		item, adjusted := s.Get(i)
+		fmt.Fprintf(w, "%d: %s original=%g adjusted=%g\n",
+			i, format(item), s.GetOriginalWeight(i), adjusted)
+	}
+}
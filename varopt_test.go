@@ -3,8 +3,13 @@
 package varopt_test
 
 import (
+	"bytes"
+	"fmt"
 	"math"
+	"math/big"
 	"math/rand"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/lightstep/varopt"
@@ -115,7 +120,7 @@ func testUnbiased(t *testing.T, bbr, bsr float64) {
 						ss.Add(s)
 					}
 
-					weight := float64(ss.Count()) / float64(ss.Size())
+					weight := ss.Weight()
 					for i := 0; i < ss.Size(); i++ {
 						vsample.Add(ss.Get(i), weight)
 					}
@@ -262,4 +267,1712 @@ func TestEject(t *testing.T) {
 		require.Equal(t, *expectItem, *ejectItem)
 		require.Equal(t, expectWeight, ejectWeight)
 	}
+
+	require.True(t, expected.Equal(ejector, func(a, b *testInt) bool {
+		return *a == *b
+	}))
+}
+
+func TestExpire(t *testing.T) {
+	const capacity = 10
+	const insert = 100
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	for i := 1.; i <= insert; i++ {
+		v.Add(testInt(i), i)
+	}
+	require.Equal(t, insert, v.TotalCount())
+
+	for i := 1.; i <= insert; i++ {
+		v.Expire(i)
+	}
+
+	require.Equal(t, 0, v.TotalCount())
+	require.Equal(t, 0., v.TotalWeight())
+	require.Equal(t, capacity, v.Size())
+
+	// The totals do not go negative.
+	v.Expire(5)
+	require.Equal(t, 0, v.TotalCount())
+	require.Equal(t, 0., v.TotalWeight())
+
+	fresh := varopt.New[testInt](capacity, rnd)
+	fresh.Expire(5)
+	require.Equal(t, 0, fresh.TotalCount())
+	require.Equal(t, 0., fresh.TotalWeight())
+
+	fresh.Add(1, 2)
+	fresh.Expire(3)
+	require.Equal(t, 1, fresh.TotalCount())
+	require.Equal(t, 2., fresh.TotalWeight())
+}
+
+func TestEmptyX(t *testing.T) {
+	const capacity = 100
+	const rounds = 100000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	for i := 0; i < rounds; i++ {
+		// Mix light and very heavy weights so that items
+		// migrate from L through X.
+		weight := rnd.ExpFloat64()
+		if i%17 == 0 {
+			weight *= 1000
+		}
+		_, err := v.Add(testInt(i), weight)
+		require.NoError(t, err)
+		require.Empty(t, varopt.DebugX(v))
+	}
+}
+
+func TestInsertionOrder(t *testing.T) {
+	const capacity = 10
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	// Decreasing weights, which a min-heap would reorder.
+	for i := 0; i < capacity; i++ {
+		v.Add(testInt(i), float64(capacity-i))
+
+		for j := 0; j <= i; j++ {
+			item, weight, ok := v.GetInsertionOrder(j)
+			require.True(t, ok)
+			require.Equal(t, testInt(j), item)
+			require.Equal(t, float64(capacity-j), weight)
+		}
+	}
+
+	v.Add(testInt(capacity), 0.5)
+
+	_, _, ok := v.GetInsertionOrder(0)
+	require.False(t, ok)
+
+	first, _ := v.Get(0)
+	require.NotEqual(t, testInt(0), first)
+
+	require.PanicsWithValue(t, "varopt: index 10 out of range with Size() 10", func() { v.GetInsertionOrder(capacity) })
+	require.PanicsWithValue(t, "varopt: index -1 out of range with Size() 10", func() { v.GetInsertionOrder(-1) })
+
+	// Pinned and sticky items follow the reservoir.
+	p := varopt.New[testInt](capacity, rnd)
+	p.SetKeepFirst(true)
+	for i := 0; i < 3; i++ {
+		p.Add(testInt(i), 1)
+	}
+	require.NoError(t, p.AddSticky(-1, 2))
+	require.Equal(t, 4, p.Size())
+	for i, expect := range []testInt{1, 2, 0, -1} {
+		item, _, ok := p.GetInsertionOrder(i)
+		require.True(t, ok)
+		require.Equal(t, expect, item)
+	}
+}
+
+func TestResetWithCapacity(t *testing.T) {
+	const insert = 1000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](10, rnd)
+
+	for _, capacity := range []int{10, 100, 5} {
+		v.ResetWithCapacity(capacity)
+
+		require.Equal(t, capacity, v.Capacity())
+		require.Equal(t, 0, v.Size())
+		require.Equal(t, 0, v.TotalCount())
+
+		sum := 0.
+		for i := 1.; i <= insert; i++ {
+			v.Add(testInt(i), i)
+			sum += i
+		}
+
+		require.Equal(t, capacity, v.Size())
+		require.Equal(t, insert, v.TotalCount())
+		require.Equal(t, sum, v.TotalWeight())
+
+		est := 0.
+		for i := 0; i < v.Size(); i++ {
+			_, w := v.Get(i)
+			est += w
+		}
+		require.InEpsilon(t, sum, est, 1e-9)
+	}
+
+	for _, capacity := range []int{0, -1} {
+		require.PanicsWithValue(t,
+			fmt.Sprintf("varopt: Zero or negative capacity (capacity %d)", capacity),
+			func() { v.ResetWithCapacity(capacity) })
+		require.Equal(t, 5, v.Capacity())
+		require.Equal(t, 5, v.Size())
+	}
+}
+
+func TestTopWeights(t *testing.T) {
+	const capacity = 3
+	const topK = 5
+	const rounds = 10000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+	v.EnableTopWeights(topK)
+
+	// The heaviest items arrive first, so that the many lighter
+	// items that follow raise the threshold and eject them.
+	for i := 0; i < rounds; i++ {
+		weight := rnd.ExpFloat64()
+		if i < topK {
+			weight = 1e3 + float64(i)
+		}
+		v.Add(testInt(i), weight)
+	}
+
+	top := v.TopWeights()
+	require.Equal(t, topK, len(top))
+	for i, s := range top {
+		require.Equal(t, testInt(topK-1-i), s.Item)
+		require.Equal(t, 1e3+float64(topK-1-i), s.Weight)
+	}
+
+	ejected := 0
+	for _, s := range top {
+		found := false
+		for i := 0; i < v.Size(); i++ {
+			item, _ := v.Get(i)
+			found = found || item == s.Item
+		}
+		if !found {
+			ejected++
+		}
+	}
+	require.Less(t, 0, ejected)
+
+	v.Reset()
+	require.Empty(t, v.TopWeights())
+}
+
+func TestTopWeightsInvalid(t *testing.T) {
+	v := varopt.New[testInt](3, rand.New(rand.NewSource(98887)))
+	require.PanicsWithValue(t, "varopt: negative top weights count -1", func() { v.EnableTopWeights(-1) })
+
+	v.EnableTopWeights(2)
+	v.Add(1, 1)
+	v.EnableTopWeights(0)
+	v.Add(2, 2)
+	require.Empty(t, v.TopWeights())
+}
+
+func TestValueBounds(t *testing.T) {
+	const capacity = 10
+	const insert = 1000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+	v.SetValueBounds(func(i testInt) float64 { return float64(i) }, 100, 199)
+
+	for i := 0; i < insert; i++ {
+		eject, err := v.Add(testInt(i), 1)
+		require.NoError(t, err)
+		if i < 100 || i > 199 {
+			require.Equal(t, testInt(i), eject)
+		}
+	}
+
+	require.Equal(t, insert, v.TotalCount())
+	require.Equal(t, float64(insert), v.TotalWeight())
+	require.Equal(t, capacity, v.Size())
+
+	est := 0.
+	for i := 0; i < v.Size(); i++ {
+		item, w := v.Get(i)
+		require.GreaterOrEqual(t, item, testInt(100))
+		require.LessOrEqual(t, item, testInt(199))
+		est += w
+	}
+	require.InEpsilon(t, 100, est, 1e-9)
+}
+
+func TestValueClamp(t *testing.T) {
+	const capacity = 10
+	const insert = 1000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+	v.SetValueClamp(func(i testInt) float64 { return float64(i) }, 100, 199,
+		func(i testInt, lo, hi float64) testInt {
+			return testInt(math.Max(lo, math.Min(hi, float64(i))))
+		})
+
+	clamped := 0
+	for i := 0; i < insert; i++ {
+		_, err := v.Add(testInt(i), 1)
+		require.NoError(t, err)
+	}
+	require.Equal(t, insert, v.TotalCount())
+	require.Equal(t, float64(insert), v.TotalWeight())
+	require.Equal(t, capacity, v.Size())
+
+	est := 0.
+	for item, w := range v.All() {
+		require.GreaterOrEqual(t, item, testInt(100))
+		require.LessOrEqual(t, item, testInt(199))
+		if item == 100 || item == 199 {
+			clamped++
+		}
+		est += w
+	}
+	require.Less(t, 0, clamped)
+	// Clamped items keep their weight in the sample.
+	require.InEpsilon(t, float64(insert), est, 1e-9)
+
+	// SetValueBounds() returns to exclusion.
+	v.SetValueBounds(func(i testInt) float64 { return float64(i) }, 100, 199)
+	eject, _ := v.Add(1000, 1)
+	require.Equal(t, testInt(1000), eject)
+}
+
+func TestAutoSeed(t *testing.T) {
+	const capacity = 10
+	const insert = 1000
+	v1, seed := varopt.NewAutoSeed[testInt](capacity)
+	v2 := varopt.New[testInt](capacity, rand.New(rand.NewSource(seed)))
+
+	for i := 1.; i <= insert; i++ {
+		v1.Add(testInt(i), i)
+		v2.Add(testInt(i), i)
+	}
+
+	for i := 0; i < capacity; i++ {
+		item1, w1 := v1.Get(i)
+		item2, w2 := v2.Get(i)
+		require.Equal(t, item1, item2)
+		require.Equal(t, w1, w2)
+	}
+}
+
+func TestWouldAdmit(t *testing.T) {
+	const capacity = 10
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	require.True(t, v.WouldAdmit(1))
+
+	for i := 1.; i <= 1000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+
+	require.True(t, v.WouldAdmit(2*v.Tau()))
+	require.True(t, v.WouldAdmit(v.Tau()/2))
+	require.False(t, v.WouldAdmit(0))
+	require.False(t, v.WouldAdmit(-1))
+	require.False(t, v.WouldAdmit(math.NaN()))
+	require.False(t, v.WouldAdmit(math.Inf(1)))
+}
+
+func TestKeepFirstLast(t *testing.T) {
+	const capacity = 10
+	const insert = 10000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+	v.SetKeepFirst(true)
+	v.SetKeepLast(true)
+
+	sum := 0.
+	for i := 0; i < insert; i++ {
+		weight := rnd.ExpFloat64()
+		sum += weight
+		v.Add(testInt(i), weight)
+
+		found := map[testInt]bool{}
+		for j := 0; j < v.Size(); j++ {
+			item, _ := v.Get(j)
+			found[item] = true
+		}
+		require.True(t, found[0])
+		require.True(t, found[testInt(i)])
+	}
+
+	require.Equal(t, capacity+2, v.Size())
+
+	est := 0.
+	for i := 0; i < v.Size(); i++ {
+		_, w := v.Get(i)
+		est += w
+	}
+	require.InEpsilon(t, sum, est, 1e-9)
+
+	v.Reset()
+	require.Equal(t, 0, v.Size())
+}
+
+func TestWarmStart(t *testing.T) {
+	const capacity = 1000
+	const insert = 100000
+	rnd := rand.New(rand.NewSource(98887))
+
+	// Sample the first half of a population.
+	prior := varopt.New[testInt](capacity, rnd)
+	psum := 0.
+	for i := 0; i < insert; i++ {
+		w := rnd.ExpFloat64()
+		psum += w * float64(i%10)
+		prior.Add(testInt(i), w)
+	}
+
+	var items []testInt
+	var weights []float64
+	for i := 0; i < prior.Size(); i++ {
+		item, w := prior.Get(i)
+		items = append(items, item)
+		weights = append(weights, w)
+	}
+
+	v := varopt.New[testInt](capacity, rnd)
+	require.Equal(t, varopt.ErrLengthMismatch, v.WarmStart(items, weights[1:]))
+	require.Equal(t, varopt.ErrInvalidWeight, v.WarmStart(items[:1], []float64{-1}))
+	require.Equal(t, 0, v.Size())
+
+	require.NoError(t, v.WarmStart(items, weights))
+	require.Equal(t, capacity, v.Size())
+	require.InEpsilon(t, prior.TotalWeight(), v.TotalWeight(), 1e-9)
+	require.Equal(t, varopt.ErrNotEmpty, v.WarmStart(items, weights))
+
+	// Continue with the second half.
+	for i := insert; i < 2*insert; i++ {
+		w := rnd.ExpFloat64()
+		psum += w * float64(i%10)
+		v.Add(testInt(i), w)
+	}
+
+	vsum := 0.
+	for i := 0; i < v.Size(); i++ {
+		item, w := v.Get(i)
+		vsum += w * float64(item%10)
+	}
+	require.InEpsilon(t, psum, vsum, epsilon)
+}
+
+func TestRandAudit(t *testing.T) {
+	const capacity = 100
+	const insert = 10000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+	v.EnableRandAudit()
+
+	for i := 0; i < capacity; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	floats, ints := v.RandDrawCount()
+	require.Equal(t, 0, floats)
+	require.Equal(t, 0, ints)
+
+	for i := capacity; i < insert; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	floats, ints = v.RandDrawCount()
+	require.Equal(t, insert-capacity, floats)
+	require.Less(t, 0, ints)
+	require.Greater(t, floats, ints)
+}
+
+func TestRescale(t *testing.T) {
+	const capacity = 1000
+	const insert = 100000
+	const factor = 0.5
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	for i := 0; i < insert; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+
+	odd := func(i testInt) bool { return i%2 == 1 }
+	estimate := func() (odds, evens float64) {
+		for i := 0; i < v.Size(); i++ {
+			item, w := v.Get(i)
+			if odd(item) {
+				odds += w
+			} else {
+				evens += w
+			}
+		}
+		return
+	}
+
+	odds, evens := estimate()
+	total := v.TotalWeight()
+
+	v.Rescale(factor, odd)
+
+	odds2, evens2 := estimate()
+	require.InEpsilon(t, odds*factor, odds2, 1e-9)
+	require.Equal(t, evens, evens2)
+	require.InEpsilon(t, total-odds*(1-factor), v.TotalWeight(), 1e-9)
+	require.Equal(t, capacity, v.Size())
+
+	// The sampler continues to work after rescaling.
+	for i := insert; i < 2*insert; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	require.Equal(t, capacity, v.Size())
+	odds3, evens3 := estimate()
+	require.InEpsilon(t, v.TotalWeight(), odds3+evens3, 1e-9)
+}
+
+func TestProjectedAdjustedWeight(t *testing.T) {
+	const capacity = 1000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	require.Equal(t, 0.5, v.ProjectedAdjustedWeight(0.5))
+
+	for i := 0; i < 100000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+
+	adjustedOf := func(item testInt) (float64, bool) {
+		for i := 0; i < v.Size(); i++ {
+			got, w := v.Get(i)
+			if got == item {
+				return w, true
+			}
+		}
+		return 0, false
+	}
+
+	// A heavy item keeps its own weight.
+	heavy := 10 * v.Tau()
+	projected := v.ProjectedAdjustedWeight(heavy)
+	require.Equal(t, heavy, projected)
+	v.Add(-1, heavy)
+	actual, ok := adjustedOf(-1)
+	require.True(t, ok)
+	require.Equal(t, projected, actual)
+
+	// A light item, if retained, carries the slightly raised
+	// threshold.
+	for i := -2; ; i-- {
+		light := v.Tau() / 2
+		projected = v.ProjectedAdjustedWeight(light)
+		require.Equal(t, v.Tau(), projected)
+		v.Add(testInt(i), light)
+		if actual, ok = adjustedOf(testInt(i)); ok {
+			break
+		}
+	}
+	require.LessOrEqual(t, projected, actual)
+	require.InEpsilon(t, projected, actual, 1e-2)
+}
+
+func TestStable(t *testing.T) {
+	const capacity = 100
+	const snapshots = 100
+	const interval = 100
+
+	churn := func(stable bool) int {
+		rnd := rand.New(rand.NewSource(98887))
+		v := varopt.New[testInt](capacity, rnd)
+		v.SetStable(stable)
+
+		total := 0
+		prev := map[testInt]bool{}
+		for s := 0; s < snapshots; s++ {
+			for i := 0; i < interval; i++ {
+				v.Add(testInt(s*interval+i), rnd.ExpFloat64())
+			}
+			next := map[testInt]bool{}
+			for i := 0; i < v.Size(); i++ {
+				item, _ := v.Get(i)
+				next[item] = true
+				if !prev[item] {
+					total++
+				}
+			}
+			prev = next
+		}
+
+		est := 0.
+		for i := 0; i < v.Size(); i++ {
+			_, w := v.Get(i)
+			est += w
+		}
+		require.InEpsilon(t, v.TotalWeight(), est, 1e-9)
+		return total
+	}
+
+	require.Less(t, churn(true), churn(false))
+}
+
+func TestMerge(t *testing.T) {
+	const capacity = 1000
+	const insert = 100000
+	rnd := rand.New(rand.NewSource(98887))
+
+	a := varopt.New[testInt](capacity, rnd)
+	b := varopt.New[testInt](capacity, rnd)
+
+	psum := 0.
+	for i := 0; i < insert; i++ {
+		w := rnd.ExpFloat64()
+		psum += w * float64(i%10)
+		if i%2 == 0 {
+			a.Add(testInt(i), w)
+		} else {
+			b.Add(testInt(i), w)
+		}
+	}
+
+	totalWeight := a.TotalWeight() + b.TotalWeight()
+
+	require.Equal(t, varopt.ErrCapacityMismatch, a.Merge(varopt.New[testInt](capacity+1, rnd)))
+	require.Equal(t, varopt.ErrNilRand, (&varopt.Varopt[testInt]{}).Merge(&varopt.Varopt[testInt]{}))
+	require.Equal(t, varopt.ErrSelfMerge, a.Merge(a))
+	require.Equal(t, insert/2, a.TotalCount())
+
+	require.NoError(t, a.Merge(b))
+	require.Equal(t, capacity, a.Size())
+	require.Equal(t, insert, a.TotalCount())
+	require.InEpsilon(t, totalWeight, a.TotalWeight(), 1e-9)
+
+	vsum := 0.
+	wsum := 0.
+	for i := 0; i < a.Size(); i++ {
+		item, w := a.Get(i)
+		vsum += w * float64(item%10)
+		wsum += w
+	}
+	require.InEpsilon(t, psum, vsum, epsilon)
+	require.InEpsilon(t, totalWeight, wsum, 1e-9)
+}
+
+// TestMergeAssociative checks, over many seeds, that the order and
+// grouping of merges does not change the distribution of subset-sum
+// estimates: each arrangement is unbiased, and their means and
+// variances agree.
+func TestMergeAssociative(t *testing.T) {
+	const (
+		capacity = 50
+		perShard = 2000
+		trials   = 3000
+	)
+
+	// Three shards with differently scaled weights, fixed across
+	// trials so that only the sampling varies.
+	prnd := rand.New(rand.NewSource(98887))
+	weights := make([][]float64, 3)
+	truth := 0.
+	for s := range weights {
+		weights[s] = make([]float64, perShard)
+		for i := range weights[s] {
+			w := prnd.ExpFloat64() * math.Pow(10, float64(s))
+			weights[s][i] = w
+			if i%3 == 0 {
+				truth += w
+			}
+		}
+	}
+
+	merged := func(dst *varopt.Varopt[testInt], srcs ...*varopt.Varopt[testInt]) *varopt.Varopt[testInt] {
+		m := dst.Clone()
+		for _, src := range srcs {
+			require.NoError(t, m.Merge(src))
+		}
+		return m
+	}
+	arrangements := []func(a, b, c *varopt.Varopt[testInt]) *varopt.Varopt[testInt]{
+		// (a + b) + c
+		func(a, b, c *varopt.Varopt[testInt]) *varopt.Varopt[testInt] {
+			return merged(merged(a, b), c)
+		},
+		// a + (b + c)
+		func(a, b, c *varopt.Varopt[testInt]) *varopt.Varopt[testInt] {
+			return merged(a, merged(b, c))
+		},
+		// (b + a) + c
+		func(a, b, c *varopt.Varopt[testInt]) *varopt.Varopt[testInt] {
+			return merged(merged(b, a), c)
+		},
+		// (c + b) + a
+		func(a, b, c *varopt.Varopt[testInt]) *varopt.Varopt[testInt] {
+			return merged(merged(c, b), a)
+		},
+	}
+
+	estimates := make([][]float64, len(arrangements))
+	for trial := 0; trial < trials; trial++ {
+		rnd := rand.New(rand.NewSource(int64(trial)))
+		shards := make([]*varopt.Varopt[testInt], 3)
+		for s := range shards {
+			shards[s] = varopt.New[testInt](capacity, rnd)
+			for i, w := range weights[s] {
+				shards[s].Add(testInt(i), w)
+			}
+		}
+		for k, arrange := range arrangements {
+			m := arrange(shards[0], shards[1], shards[2])
+			est := 0.
+			for item, w := range m.All() {
+				if item%3 == 0 {
+					est += w
+				}
+			}
+			estimates[k] = append(estimates[k], est)
+		}
+	}
+
+	meanVar := func(xs []float64) (float64, float64) {
+		m, v := 0., 0.
+		for _, x := range xs {
+			m += x / float64(len(xs))
+		}
+		for _, x := range xs {
+			v += (x - m) * (x - m) / float64(len(xs)-1)
+		}
+		return m, v
+	}
+	mean0, var0 := meanVar(estimates[0])
+	for k := range arrangements {
+		mean, variance := meanVar(estimates[k])
+		stderr := math.Sqrt(variance / trials)
+		require.InDelta(t, truth, mean, 4*stderr, "arrangement %d", k)
+		require.InDelta(t, mean0, mean, 4*math.Sqrt((var0+variance)/trials), "arrangement %d", k)
+		require.InDelta(t, 1, variance/var0, 0.35, "arrangement %d", k)
+	}
+}
+
+func TestMergeAll(t *testing.T) {
+	const capacity = 1000
+	const shards = 50
+	const insert = 100000
+	rnd := rand.New(rand.NewSource(98887))
+
+	full := varopt.New[testInt](capacity, rnd)
+	sources := make([]*varopt.Varopt[testInt], shards)
+	for i := range sources {
+		sources[i] = varopt.New[testInt](capacity, rnd)
+	}
+
+	psum := 0.
+	for i := 0; i < insert; i++ {
+		w := rnd.ExpFloat64()
+		psum += w * float64(i%10)
+		full.Add(testInt(i), w)
+		sources[i%shards].Add(testInt(i), w)
+	}
+
+	dst := varopt.New[testInt](capacity, rnd)
+	require.Equal(t, varopt.ErrCapacityMismatch,
+		varopt.MergeAll(dst, sources[0], varopt.New[testInt](capacity+1, rnd)))
+	require.Equal(t, 0, dst.Size())
+	require.Equal(t, 0, dst.TotalCount())
+	require.Equal(t, varopt.ErrSelfMerge, varopt.MergeAll(dst, sources[0], dst))
+	require.Equal(t, 0, dst.TotalCount())
+
+	require.NoError(t, varopt.MergeAll(dst, sources...))
+	require.Equal(t, capacity, dst.Size())
+	require.Equal(t, full.TotalCount(), dst.TotalCount())
+	require.InEpsilon(t, full.TotalWeight(), dst.TotalWeight(), 1e-9)
+
+	estimate := func(v *varopt.Varopt[testInt]) float64 {
+		sum := 0.
+		for item, w := range v.All() {
+			sum += w * float64(item%10)
+		}
+		return sum
+	}
+	require.InEpsilon(t, psum, estimate(full), epsilon)
+	require.InEpsilon(t, psum, estimate(dst), epsilon)
+	require.InEpsilon(t, estimate(full), estimate(dst), 2*epsilon)
+}
+
+func TestIterators(t *testing.T) {
+	const capacity = 100
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	for i := 0; i < 10000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+
+	i := 0
+	for item, weight := range v.All() {
+		expectItem, expectWeight := v.Get(i)
+		require.Equal(t, expectItem, item)
+		require.Equal(t, expectWeight, weight)
+		i++
+	}
+	require.Equal(t, capacity, i)
+
+	i = 0
+	for item, weight := range v.AllOriginal() {
+		expectItem, _ := v.Get(i)
+		require.Equal(t, expectItem, item)
+		require.Equal(t, v.GetOriginalWeight(i), weight)
+		i++
+	}
+	require.Equal(t, capacity, i)
+
+	i = 0
+	for range v.All() {
+		i++
+		if i == 10 {
+			break
+		}
+	}
+	require.Equal(t, 10, i)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		for range v.All() {
+		}
+	})
+	require.Equal(t, 0., allocs)
+}
+
+func TestAddBatch(t *testing.T) {
+	const capacity = 100
+	const rounds = 100
+	const batch = 100
+
+	single := varopt.New[testInt](capacity, rand.New(rand.NewSource(98887)))
+	batched := varopt.New[testInt](capacity, rand.New(rand.NewSource(98887)))
+	vsrc := rand.New(rand.NewSource(98887))
+
+	_, err := batched.AddBatch([]testInt{1, 2}, []float64{1})
+	require.Equal(t, varopt.ErrLengthMismatch, err)
+	_, err = batched.AddBatch([]testInt{1, 2}, []float64{1, math.NaN()})
+	require.Equal(t, varopt.ErrInvalidWeight, err)
+	require.Equal(t, 0, batched.TotalCount())
+	require.Equal(t, 0, batched.Size())
+
+	for r := 0; r < rounds; r++ {
+		items := make([]testInt, batch)
+		weights := make([]float64, batch)
+		var expect []testInt
+		for i := range items {
+			items[i] = testInt(r*batch + i)
+			weights[i] = vsrc.ExpFloat64()
+
+			if r == 0 && i < capacity {
+				single.Add(items[i], weights[i])
+				continue
+			}
+			eject, _ := single.Add(items[i], weights[i])
+			expect = append(expect, eject)
+		}
+
+		ejected, err := batched.AddBatch(items, weights)
+		require.NoError(t, err)
+		require.Equal(t, expect, ejected)
+	}
+
+	require.Equal(t, single.TotalCount(), batched.TotalCount())
+	require.Equal(t, single.TotalWeight(), batched.TotalWeight())
+	require.Equal(t, single.Tau(), batched.Tau())
+	for i := 0; i < capacity; i++ {
+		expectItem, expectWeight := single.Get(i)
+		item, weight := batched.Get(i)
+		require.Equal(t, expectItem, item)
+		require.Equal(t, expectWeight, weight)
+	}
+}
+
+func TestResize(t *testing.T) {
+	const capacity = 1000
+	const insert = 100000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	require.Equal(t, varopt.ErrInvalidCapacity, v.Resize(0))
+	require.Equal(t, varopt.ErrInvalidCapacity, v.Resize(-1))
+
+	psum := 0.
+	for i := 0; i < insert; i++ {
+		w := rnd.ExpFloat64()
+		psum += w * float64(i%10)
+		v.Add(testInt(i), w)
+	}
+
+	require.NoError(t, v.Resize(capacity/4))
+	require.Equal(t, capacity/4, v.Capacity())
+	require.Equal(t, capacity/4, v.Size())
+
+	vsum := 0.
+	wsum := 0.
+	for i := 0; i < v.Size(); i++ {
+		item, w := v.Get(i)
+		vsum += w * float64(item%10)
+		wsum += w
+	}
+	require.InEpsilon(t, psum, vsum, epsilon)
+	require.InEpsilon(t, v.TotalWeight(), wsum, 1e-9)
+	require.LessOrEqual(t, v.MaxHeavyItems(), capacity/4+1)
+	require.NoError(t, varopt.CheckInvariants(v))
+
+	// Growing an unfilled sampler behaves like a fresh larger one.
+	grown := varopt.New[testInt](10, rnd)
+	fresh := varopt.New[testInt](capacity, rnd)
+	for i := 0; i < 5; i++ {
+		grown.Add(testInt(i), float64(i+1))
+		fresh.Add(testInt(i), float64(i+1))
+	}
+	require.NoError(t, grown.Resize(capacity))
+	for i := 5; i < capacity; i++ {
+		grown.Add(testInt(i), float64(i+1))
+		fresh.Add(testInt(i), float64(i+1))
+	}
+	require.Equal(t, capacity, grown.Size())
+	require.NoError(t, varopt.CheckInvariants(grown))
+	for i := 0; i < capacity; i++ {
+		expectItem, expectWeight := fresh.Get(i)
+		item, weight := grown.Get(i)
+		require.Equal(t, expectItem, item)
+		require.Equal(t, expectWeight, weight)
+	}
+}
+
+// scriptedRand returns predetermined values, recording the arguments
+// to Intn.
+type scriptedRand struct {
+	floats []float64
+	ints   []int
+	intns  []int
+}
+
+func (s *scriptedRand) Float64() float64 {
+	f := s.floats[0]
+	s.floats = s.floats[1:]
+	return f
+}
+
+func (s *scriptedRand) Intn(n int) int {
+	s.intns = append(s.intns, n)
+	i := s.ints[0]
+	s.ints = s.ints[1:]
+	return i
+}
+
+func TestScriptedRand(t *testing.T) {
+	rnd := &scriptedRand{
+		floats: []float64{0.9, 0.99},
+		ints:   []int{0},
+	}
+	v := varopt.NewWithRand[string](2, rnd)
+
+	v.Add("a", 1)
+	v.Add("b", 2)
+
+	// Threshold 1.5; the uniform 0.9 falls in the interval that
+	// ejects "a" from the candidates.
+	eject, err := v.Add("c", 0.5)
+	require.NoError(t, err)
+	require.Equal(t, "a", eject)
+	require.Equal(t, 1.5, v.Tau())
+
+	// Threshold 2.45; the uniform 0.99 exceeds the candidates'
+	// intervals, so the single light item "c" is ejected.
+	eject, err = v.Add("d", 1.4)
+	require.NoError(t, err)
+	require.Equal(t, "c", eject)
+	require.InEpsilon(t, 2.45, v.Tau(), 1e-12)
+
+	require.Empty(t, rnd.floats)
+	require.Empty(t, rnd.ints)
+	require.Equal(t, []int{1}, rnd.intns)
+	require.Equal(t, 2, v.Size())
+}
+
+func TestClone(t *testing.T) {
+	const capacity = 100
+	const insert = 10000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	for i := 0; i < insert; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+
+	c := v.CloneWithRand(rand.New(rand.NewSource(12345)))
+
+	sample := func(v *varopt.Varopt[testInt]) []varopt.Sampled[testInt] {
+		var s []varopt.Sampled[testInt]
+		for item, w := range v.All() {
+			s = append(s, varopt.Sampled[testInt]{Item: item, Weight: w})
+		}
+		return s
+	}
+
+	before := sample(v)
+	require.Equal(t, before, sample(c))
+	require.Equal(t, v.TotalCount(), c.TotalCount())
+	require.Equal(t, v.TotalWeight(), c.TotalWeight())
+	require.Equal(t, v.Tau(), c.Tau())
+
+	for i := 0; i < insert; i++ {
+		v.Add(testInt(insert+i), rnd.ExpFloat64())
+		c.Add(testInt(-i), rnd.ExpFloat64())
+	}
+
+	require.NotEqual(t, sample(v), sample(c))
+	for item := range v.All() {
+		require.Less(t, testInt(-1), item)
+	}
+	for item := range c.All() {
+		require.Greater(t, testInt(insert), item)
+	}
+
+	c2 := c.Clone()
+	require.Equal(t, sample(c), sample(c2))
+}
+
+func TestInclusionProbability(t *testing.T) {
+	const capacity = 100
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	for i := 0; i < 10000; i++ {
+		w := rnd.ExpFloat64()
+		if i%100 == 0 {
+			w *= 1000
+		}
+		v.Add(testInt(i), w)
+	}
+
+	heavy := 0
+	for i := 0; i < v.Size(); i++ {
+		_, adjusted := v.Get(i)
+		p := v.InclusionProbability(i)
+		require.Less(t, 0., p)
+		require.LessOrEqual(t, p, 1.)
+		require.InEpsilon(t, adjusted, v.GetOriginalWeight(i)/p, 1e-12)
+		if p == 1 {
+			heavy++
+		}
+	}
+	require.Less(t, 0, heavy)
+	require.Greater(t, capacity, heavy)
+
+	require.PanicsWithValue(t, "varopt: index -5 out of range with Size() 100", func() { v.InclusionProbability(-5) })
+	require.PanicsWithValue(t, "varopt: index 100 out of range with Size() 100", func() { v.InclusionProbability(capacity) })
+}
+
+func TestAddReport(t *testing.T) {
+	const capacity = 20
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	expected, observed := 0., 0
+	for i := 0; i < 100000; i++ {
+		w := rnd.ExpFloat64()
+		if i%50 == 0 {
+			w *= 100
+		}
+		ejected, info, err := v.AddReport(testInt(i), w)
+		require.NoError(t, err)
+		require.Equal(t, i >= capacity, info.Ejected)
+		require.Equal(t, v.Tau(), info.Tau)
+
+		if !info.Ejected {
+			require.True(t, info.Heavy)
+			continue
+		}
+		require.Less(t, 0., info.EjectProbability)
+		require.LessOrEqual(t, info.EjectProbability, 1.)
+		require.LessOrEqual(t, info.SelfEjectProbability, 1.)
+		if info.SelfEjected {
+			require.Equal(t, testInt(i), ejected)
+			require.False(t, info.Heavy)
+			require.Equal(t, info.SelfEjectProbability, info.EjectProbability)
+		}
+		if info.Heavy {
+			require.Equal(t, 0., info.SelfEjectProbability)
+		}
+		expected += info.SelfEjectProbability
+		if info.SelfEjected {
+			observed++
+		}
+	}
+	require.Less(t, 1000., expected)
+	require.InEpsilon(t, expected, float64(observed), 0.02)
+
+	_, _, err := v.AddReport(1, math.NaN())
+	require.Equal(t, varopt.ErrInvalidWeight, err)
+}
+
+func TestEqual(t *testing.T) {
+	const capacity = 100
+	eq := func(a, b testInt) bool { return a == b }
+
+	v1 := varopt.New[testInt](capacity, rand.New(rand.NewSource(98887)))
+	v2 := varopt.New[testInt](capacity, rand.New(rand.NewSource(98887)))
+	require.True(t, v1.Equal(v2, eq))
+
+	vsrc := rand.New(rand.NewSource(98887))
+	for i := 0; i < 10000; i++ {
+		w := vsrc.ExpFloat64()
+		v1.Add(testInt(i), w)
+		v2.Add(testInt(i), w)
+	}
+	require.True(t, v1.Equal(v2, eq))
+	require.True(t, v2.Equal(v1, eq))
+	require.True(t, v1.Equal(v1.Clone(), eq))
+
+	// Heavy enough to be retained by both.
+	v1.Add(10000, 1e6)
+	v2.Add(10001, 1e6)
+	require.False(t, v1.Equal(v2, eq))
+	require.False(t, v2.Equal(v1, eq))
+
+	v3 := varopt.New[testInt](capacity+1, rand.New(rand.NewSource(98887)))
+	require.False(t, varopt.New[testInt](capacity, rand.New(rand.NewSource(98887))).Equal(v3, eq))
+}
+
+func TestInvalidNew(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+
+	for _, capacity := range []int{0, -1} {
+		v, err := varopt.TryNew[testInt](capacity, rnd)
+		require.Nil(t, v)
+		require.Equal(t, varopt.ErrInvalidCapacity, err)
+
+		require.Panics(t, func() { varopt.New[testInt](capacity, rnd) })
+		require.Panics(t, func() { (&varopt.Varopt[testInt]{}).Init(capacity, rnd) })
+	}
+
+	v, err := varopt.TryNew[testInt](1, nil)
+	require.Nil(t, v)
+	require.Equal(t, varopt.ErrNilRand, err)
+
+	require.Panics(t, func() { varopt.New[testInt](1, nil) })
+	require.Panics(t, func() { varopt.NewWithRand[testInt](1, nil) })
+
+	v, err = varopt.TryNew[testInt](1, rnd)
+	require.NoError(t, err)
+	require.Equal(t, 1, v.Capacity())
+}
+
+func TestTotalCount64(t *testing.T) {
+	v := varopt.New[testInt](10, rand.New(rand.NewSource(98887)))
+	varopt.SetTotalCount(v, math.MaxInt32-5)
+
+	for i := 0; i < 10; i++ {
+		v.Add(testInt(i), 1)
+	}
+	require.Equal(t, int64(math.MaxInt32+5), v.TotalCount64())
+	require.Less(t, int64(0), v.TotalCount64())
+}
+
+func TestTotalWeightCompensated(t *testing.T) {
+	v := varopt.New[testInt](10, rand.New(rand.NewSource(98887)))
+	ref := new(big.Float).SetPrec(256)
+
+	add := func(w float64) {
+		v.Add(0, w)
+		ref.Add(ref, new(big.Float).SetFloat64(w))
+	}
+
+	add(1e16)
+	for i := 0; i < 10000000; i++ {
+		add(0.1)
+	}
+	add(3e16)
+	add(1e-3)
+
+	expect, _ := ref.Float64()
+	require.Equal(t, expect, v.TotalWeight())
+}
+
+func TestAppendTo(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](100, rnd)
+
+	for i := 0; i < 10000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+
+	prefix := varopt.Weighted[testInt]{Item: -1}
+	buf := v.AppendTo([]varopt.Weighted[testInt]{prefix})
+	require.Equal(t, v.Size()+1, len(buf))
+	require.Equal(t, prefix, buf[0])
+	for i := 0; i < v.Size(); i++ {
+		item, weight := v.Get(i)
+		require.Equal(t, varopt.Weighted[testInt]{
+			Item:                 item,
+			AdjustedWeight:       weight,
+			OriginalWeight:       v.GetOriginalWeight(i),
+			InclusionProbability: v.InclusionProbability(i),
+		}, buf[i+1])
+	}
+
+	require.Equal(t, 0., testing.AllocsPerRun(10, func() {
+		buf = v.AppendTo(buf[:0])
+	}))
+}
+
+func TestResetReservoir(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](100, rnd)
+	v.SetKeepFirst(true)
+
+	for i := 0; i < 10000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	count, weight := v.TotalCount(), v.TotalWeight()
+
+	v.ResetReservoir()
+	require.Equal(t, 0, v.Size())
+	require.Equal(t, 0., v.Tau())
+	require.Equal(t, count, v.TotalCount())
+	require.Equal(t, weight, v.TotalWeight())
+
+	v.Add(10000, 1)
+	require.Equal(t, 1, v.Size())
+	require.Equal(t, count+1, v.TotalCount())
+
+	v.Reset()
+	require.Equal(t, 0, v.Size())
+	require.Equal(t, 0, v.TotalCount())
+}
+
+func TestMinLargeWeight(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](10, rnd)
+
+	_, ok := v.MinLargeWeight()
+	require.False(t, ok)
+
+	for i, w := range []float64{5, 3, 4} {
+		v.Add(testInt(i), w)
+	}
+	least, ok := v.MinLargeWeight()
+	require.True(t, ok)
+	require.Equal(t, 3., least)
+
+	for i := 0; i < 1000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	v.Add(1000, 1e6)
+	least, ok = v.MinLargeWeight()
+	require.True(t, ok)
+	require.Greater(t, least, v.Tau())
+	for i := 0; i < v.Size(); i++ {
+		if v.InclusionProbability(i) == 1 {
+			require.LessOrEqual(t, least, v.GetOriginalWeight(i))
+		}
+	}
+}
+
+func TestAddNoAllocs(t *testing.T) {
+	const capacity = 100000
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	weight := func(i int) float64 {
+		// Occasional heavy items keep L populated, so that
+		// items migrate from L to X.
+		if i%100 == 0 {
+			return 1e4 * rnd.ExpFloat64()
+		}
+		return rnd.ExpFloat64()
+	}
+	for i := 0; i < 3*capacity; i++ {
+		v.Add(testInt(i), weight(i))
+	}
+
+	i := 0
+	require.Equal(t, 0., testing.AllocsPerRun(capacity, func() {
+		v.Add(testInt(i), weight(i))
+		i++
+	}))
+}
+
+func TestMaxHeavyItems(t *testing.T) {
+	const capacity = 100
+	v := varopt.New[testInt](capacity, rand.New(rand.NewSource(98887)))
+	require.Equal(t, 0, v.MaxHeavyItems())
+
+	// Increasing weights are the worst case for L: each new item
+	// is heavy, and the earlier ones must be drained.
+	w := 1.
+	for i := 0; i < 10000; i++ {
+		v.Add(testInt(i), w)
+		w *= 1.01
+		require.NoError(t, varopt.CheckInvariants(v))
+		require.LessOrEqual(t, v.MaxHeavyItems(), capacity+1)
+	}
+	require.Equal(t, capacity+1, v.MaxHeavyItems())
+
+	v.Reset()
+	require.Equal(t, 0, v.MaxHeavyItems())
+}
+
+func TestStringDump(t *testing.T) {
+	v := varopt.New[testInt](3, rand.New(rand.NewSource(98887)))
+	for i := 1; i <= 5; i++ {
+		v.Add(testInt(i), float64(i))
+	}
+
+	str := v.String()
+	for _, field := range []string{
+		"capacity: 3",
+		"size: 3",
+		fmt.Sprintf("tau: %g", v.Tau()),
+		"count: 5",
+		"weight: 15",
+	} {
+		require.Contains(t, str, field)
+	}
+
+	var buf bytes.Buffer
+	v.Dump(&buf, func(i testInt) string {
+		return fmt.Sprint("item", int(i))
+	})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(t, 1+v.Size(), len(lines))
+	require.Equal(t, str, lines[0])
+	for i := 0; i < v.Size(); i++ {
+		item, adjusted := v.Get(i)
+		require.Equal(t, fmt.Sprintf("%d: item%d original=%g adjusted=%g",
+			i, int(item), v.GetOriginalWeight(i), adjusted), lines[i+1])
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	const capacity = 20
+	less := func(a, b testInt) bool { return a < b }
+	eq := func(a, b testInt) bool { return a == b }
+
+	// The same equal-weight items, with a few heavier ones, in two
+	// arrival orders, followed by the same continuation.
+	var first []testInt
+	for i := 0; i < capacity; i++ {
+		first = append(first, testInt(i))
+	}
+	weight := func(i testInt) float64 {
+		if i%7 == 0 {
+			return 3
+		}
+		return 1
+	}
+	second := slices.Clone(first)
+	rand.New(rand.NewSource(1)).Shuffle(len(second), func(i, j int) {
+		second[i], second[j] = second[j], second[i]
+	})
+	require.NotEqual(t, first, second)
+
+	run := func(order []testInt) *varopt.Varopt[testInt] {
+		v := varopt.New[testInt](capacity, rand.New(rand.NewSource(98887)))
+		v.SetDeterministic(less)
+		for _, i := range order {
+			v.Add(i, weight(i))
+		}
+		for i := capacity; i < 100; i++ {
+			v.Add(testInt(i), weight(testInt(i)))
+		}
+		return v
+	}
+	require.True(t, run(first).Equal(run(second), eq))
+}
+
+func TestNumHeavyLight(t *testing.T) {
+	const capacity = 100
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	for i := 0; i < capacity; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	require.Equal(t, capacity, v.NumHeavy())
+	require.Equal(t, 0, v.NumLight())
+
+	for i := capacity; i < 10000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+		require.Equal(t, v.Size(), v.NumHeavy()+v.NumLight())
+	}
+
+	v.Add(-1, 1e9)
+	require.Equal(t, v.Size(), v.NumHeavy()+v.NumLight())
+	require.Less(t, 0, v.NumHeavy())
+	found := false
+	for i := 0; i < v.Size(); i++ {
+		if item, w := v.Get(i); item == -1 {
+			found = true
+			require.Equal(t, 1e9, w)
+			require.Equal(t, 1., v.InclusionProbability(i))
+		}
+	}
+	require.True(t, found)
+
+	v.SetKeepLast(true)
+	v.Add(-2, 1)
+	require.Equal(t, v.Size(), v.NumHeavy()+v.NumLight())
+}
+
+func TestOnEject(t *testing.T) {
+	const capacity = 100
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	weights := map[testInt]float64{}
+	var calls []testInt
+	v.OnEject(func(item testInt, weight float64) {
+		require.Equal(t, weights[item], weight)
+		calls = append(calls, item)
+	})
+
+	var ejected []testInt
+	for i := 0; i < 10000; i++ {
+		w := rnd.ExpFloat64()
+		weights[testInt(i)] = w
+		eject, err := v.Add(testInt(i), w)
+		require.NoError(t, err)
+		if i >= capacity {
+			ejected = append(ejected, eject)
+		}
+	}
+	require.Equal(t, ejected, calls)
+
+	calls = calls[:0]
+	require.NoError(t, v.Resize(capacity/2))
+	require.Equal(t, capacity/2, len(calls))
+
+	calls = calls[:0]
+	v.SetValueBounds(func(i testInt) float64 { return float64(i) }, 0, math.Inf(1))
+	weights[-1] = 1
+	v.Add(-1, 1)
+	require.Equal(t, []testInt{-1}, calls)
+
+	calls = calls[:0]
+	var remaining []testInt
+	for item := range v.All() {
+		remaining = append(remaining, item)
+	}
+	v.Reset()
+	require.ElementsMatch(t, remaining, calls)
+
+	v.OnEject(nil)
+	v.Add(1, 1)
+	v.Reset()
+}
+
+func TestWeightRange(t *testing.T) {
+	v := varopt.New[testInt](5, rand.New(rand.NewSource(98887)))
+
+	lo, hi := v.WeightRange()
+	require.Equal(t, 0., lo)
+	require.Equal(t, 0., hi)
+
+	for i, w := range []float64{3, 1, 4, 1.5, 9} {
+		v.Add(testInt(i), w)
+	}
+	lo, hi = v.WeightRange()
+	require.Equal(t, 1., lo)
+	require.Equal(t, 9., hi)
+
+	for i := 5; i < 1000; i++ {
+		v.Add(testInt(i), 2)
+	}
+	v.Add(1000, 1e6)
+	lo, hi = v.WeightRange()
+	require.Equal(t, 1e6, hi)
+	for i := 0; i < v.Size(); i++ {
+		require.LessOrEqual(t, lo, v.GetOriginalWeight(i))
+	}
+}
+
+func TestAddSticky(t *testing.T) {
+	const capacity = 10
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+	v.SetKeepFirst(true)
+
+	require.Equal(t, varopt.ErrInvalidWeight, v.AddSticky(-1, math.NaN()))
+	require.NoError(t, v.AddSticky(-1, 0.001))
+
+	sum := 0.001
+	for i := 0; i < 10000; i++ {
+		w := rnd.ExpFloat64()
+		sum += w
+		v.Add(testInt(i), w)
+		if i == 5000 {
+			require.NoError(t, v.AddSticky(-2, 0.002))
+			sum += 0.002
+		}
+	}
+	require.Equal(t, capacity+3, v.Size())
+	require.Equal(t, 10002, v.TotalCount())
+	require.InEpsilon(t, sum, v.TotalWeight(), 1e-12)
+
+	first, _ := v.Get(capacity)
+	require.Equal(t, testInt(0), first)
+	for i, expect := range map[int]testInt{capacity + 1: -1, capacity + 2: -2} {
+		item, adjusted := v.Get(i)
+		require.Equal(t, expect, item)
+		require.Equal(t, v.GetOriginalWeight(i), adjusted)
+		require.Equal(t, 1., v.InclusionProbability(i))
+	}
+
+	require.NoError(t, v.Resize(1))
+	require.Equal(t, 4, v.Size())
+
+	v.ResetReservoir()
+	require.Equal(t, 0, v.Size())
+}
+
+func TestSampleUniform(t *testing.T) {
+	const (
+		categories = 3
+		draws      = 100000
+	)
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](1000, rnd)
+
+	require.Nil(t, v.SampleUniform(10))
+
+	for i := 0; i < 100000; i++ {
+		// Category c has weights around c+1.
+		c := i % categories
+		v.Add(testInt(i), float64(c+1)*rnd.ExpFloat64())
+	}
+	require.Nil(t, v.SampleUniform(0))
+	require.Nil(t, v.SampleUniform(-1))
+
+	var expect [categories]float64
+	for item, w := range v.All() {
+		expect[int(item)%categories] += w / v.TotalWeight()
+	}
+
+	var count [categories]float64
+	drawn := v.SampleUniform(draws)
+	require.Equal(t, draws, len(drawn))
+	for _, item := range drawn {
+		count[int(item)%categories]++
+	}
+	for c := range count {
+		require.InEpsilon(t, expect[c], count[c]/draws, 0.03)
+	}
+}
+
+func TestGetOutOfRange(t *testing.T) {
+	v := varopt.New[testInt](3, rand.New(rand.NewSource(98887)))
+	v.SetKeepFirst(true)
+	for i := 0; i < 10; i++ {
+		v.Add(testInt(i), 1)
+	}
+	require.Equal(t, 4, v.Size())
+
+	for i := 0; i < v.Size(); i++ {
+		item, weight, ok := v.TryGet(i)
+		require.True(t, ok)
+		gi, gw := v.Get(i)
+		require.Equal(t, gi, item)
+		require.Equal(t, gw, weight)
+	}
+
+	for _, i := range []int{-1, 4, 100} {
+		_, _, ok := v.TryGet(i)
+		require.False(t, ok)
+	}
+	require.PanicsWithValue(t, "varopt: index -1 out of range with Size() 4", func() { v.Get(-1) })
+	require.PanicsWithValue(t, "varopt: index 4 out of range with Size() 4", func() { v.Get(4) })
+	require.PanicsWithValue(t, "varopt: index 4 out of range with Size() 4", func() { v.GetOriginalWeight(4) })
+	require.PanicsWithValue(t, "varopt: index -1 out of range with Size() 4", func() { v.GetOriginalWeight(-1) })
+}
+
+func TestMemStats(t *testing.T) {
+	const capacity = 100
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+
+	heavy, light, tempCap := v.MemStats()
+	require.Equal(t, 0, heavy)
+	require.Equal(t, 0, light)
+	require.Equal(t, capacity+1, tempCap)
+
+	for i := 0; i < 10000; i++ {
+		w := rnd.ExpFloat64()
+		if i%1000 == 0 {
+			w = 1e6
+		}
+		v.Add(testInt(i), w)
+
+		heavy, light, tempCap = v.MemStats()
+		require.Equal(t, v.NumHeavy(), heavy)
+		require.Equal(t, v.NumLight(), light)
+		require.Equal(t, v.Size(), heavy+light)
+		require.Equal(t, capacity+1, tempCap)
+	}
+	require.NotZero(t, heavy)
+	require.NotZero(t, light)
+}
+
+func TestGetWeighted(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](100, rnd)
+	v.SetKeepFirst(true)
+
+	for i := 0; i < 10000; i++ {
+		w := rnd.ExpFloat64()
+		if i%1000 == 0 {
+			w = 1e6
+		}
+		v.Add(testInt(i), w)
+	}
+	require.NoError(t, v.AddSticky(-1, 2))
+
+	for i := 0; i < v.Size(); i++ {
+		item, weight := v.Get(i)
+		require.Equal(t, varopt.Weighted[testInt]{
+			Item:                 item,
+			AdjustedWeight:       weight,
+			OriginalWeight:       v.GetOriginalWeight(i),
+			InclusionProbability: v.InclusionProbability(i),
+		}, v.GetWeighted(i))
+	}
+	require.Panics(t, func() { v.GetWeighted(v.Size()) })
+}
+
+func TestSeal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](10, rnd)
+	for i := 0; i < 100; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	require.False(t, v.Sealed())
+	v.Seal()
+	require.True(t, v.Sealed())
+
+	size, count, weight := v.Size(), v.TotalCount(), v.TotalWeight()
+	first, firstWeight := v.Get(0)
+
+	_, err := v.Add(100, 1)
+	require.Equal(t, varopt.ErrSealed, err)
+	_, _, err = v.AddReport(100, 1)
+	require.Equal(t, varopt.ErrSealed, err)
+	_, err = v.AddBatch([]testInt{100}, []float64{1})
+	require.Equal(t, varopt.ErrSealed, err)
+	require.Equal(t, varopt.ErrSealed, v.AddSticky(100, 1))
+	require.Equal(t, varopt.ErrSealed, v.Resize(20))
+	require.Equal(t, varopt.ErrSealed, v.Merge(varopt.New[testInt](10, rnd)))
+
+	require.PanicsWithValue(t, "varopt: Sampler is sealed", func() { v.Reset() })
+	require.Panics(t, func() { v.ResetReservoir() })
+	require.Panics(t, func() { v.Rescale(2, func(testInt) bool { return true }) })
+	require.Panics(t, func() { v.CopyFrom(varopt.New[testInt](10, rnd)) })
+
+	// Reads are unaffected.
+	require.Equal(t, size, v.Size())
+	require.Equal(t, count, v.TotalCount())
+	require.Equal(t, weight, v.TotalWeight())
+	item, itemWeight := v.Get(0)
+	require.Equal(t, first, item)
+	require.Equal(t, firstWeight, itemWeight)
+	require.Equal(t, 10, v.Capacity())
+
+	v.Init(10, rnd)
+	require.False(t, v.Sealed())
+	_, err = v.Add(1, 1)
+	require.NoError(t, err)
+}
+
+func TestView(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](100, rnd)
+	v.SetKeepLast(true)
+	for i := 0; i < 10000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+
+	sum := 0.
+	n := 0
+	v.View(func(i int, item testInt, adjusted, original float64) {
+		require.Equal(t, n, i)
+		require.Equal(t, v.GetWeighted(i), varopt.Weighted[testInt]{
+			Item:                 item,
+			AdjustedWeight:       adjusted,
+			OriginalWeight:       original,
+			InclusionProbability: v.InclusionProbability(i),
+		})
+		sum += adjusted
+		n++
+	})
+	require.Equal(t, v.Size(), n)
+	require.InEpsilon(t, v.TotalWeight(), sum, 1e-9)
+
+	require.Equal(t, 0., testing.AllocsPerRun(10, func() {
+		v.View(func(_ int, _ testInt, adjusted, _ float64) {
+			sum += adjusted
+		})
+	}))
+}
+
+func TestHeavyHitters(t *testing.T) {
+	const capacity = 100
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](capacity, rnd)
+	require.Empty(t, v.HeavyHitters())
+
+	// A few dominant items among noise.
+	dominant := map[testInt]float64{
+		1000: 1e4,
+		3000: 3e4,
+		5000: 2e4,
+		7000: 5e4,
+	}
+	for i := 0; i < 10000; i++ {
+		w := rnd.ExpFloat64()
+		if dw, ok := dominant[testInt(i)]; ok {
+			w = dw
+		}
+		v.Add(testInt(i), w)
+	}
+	require.NoError(t, v.AddSticky(-1, 1e6))
+
+	heavy := v.HeavyHitters()
+	require.Len(t, heavy, len(dominant))
+	for i, h := range []testInt{7000, 3000, 5000, 1000} {
+		require.Equal(t, varopt.Weighted[testInt]{
+			Item:                 h,
+			AdjustedWeight:       dominant[h],
+			OriginalWeight:       dominant[h],
+			InclusionProbability: 1,
+		}, heavy[i])
+		require.Less(t, v.Tau(), heavy[i].OriginalWeight)
+	}
+}
+
+func TestHeavyHittersAfterResize(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](10, rnd)
+	for i := 0; i < 1000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64()+1)
+	}
+	require.NoError(t, v.Resize(20))
+	v.Add(-1, 0.001)
+
+	for _, h := range v.HeavyHitters() {
+		require.Less(t, v.Tau(), h.OriginalWeight)
+	}
+	require.Empty(t, v.HeavyHitters())
+}
+
+func TestMaxHeavyItemsAfterResize(t *testing.T) {
+	rnd := rand.New(rand.NewSource(98887))
+	v := varopt.New[testInt](100, rnd)
+	for i := 0; i < 100; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64())
+	}
+	require.Equal(t, 100, v.MaxHeavyItems())
+
+	require.NoError(t, v.Resize(10))
+	require.LessOrEqual(t, v.MaxHeavyItems(), v.Capacity()+1)
+	require.NoError(t, varopt.CheckInvariants(v))
+
+	// Growing leaves items below tau in L, which is valid.
+	for i := 100; i < 1000; i++ {
+		v.Add(testInt(i), rnd.ExpFloat64()+1)
+	}
+	require.NoError(t, v.Resize(20))
+	v.Add(-1, 0.001)
+	require.NoError(t, varopt.CheckInvariants(v))
+
+	v.Rescale(0.001, func(testInt) bool { return true })
+	require.NoError(t, varopt.CheckInvariants(v))
 }
@@ -0,0 +1,10 @@
+// Copyright 2019, LightStep Inc.
+
+/*
+Package varoptpb holds the protocol buffer schema for VarOpt sampler
+state, used by Varopt.ToProto() and Varopt.FromProto().  The Go code
+is generated from varopt.proto.
+*/
+package varoptpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative varopt.proto
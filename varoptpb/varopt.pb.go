@@ -0,0 +1,296 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: varopt.proto
+
+package varoptpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Sample_Kind int32
+
+const (
+	Sample_RESERVOIR Sample_Kind = 0
+	Sample_FIRST     Sample_Kind = 1
+	Sample_LAST      Sample_Kind = 2
+	Sample_STICKY    Sample_Kind = 3
+)
+
+// Enum value maps for Sample_Kind.
+var (
+	Sample_Kind_name = map[int32]string{
+		0: "RESERVOIR",
+		1: "FIRST",
+		2: "LAST",
+		3: "STICKY",
+	}
+	Sample_Kind_value = map[string]int32{
+		"RESERVOIR": 0,
+		"FIRST":     1,
+		"LAST":      2,
+		"STICKY":    3,
+	}
+)
+
+func (x Sample_Kind) Enum() *Sample_Kind {
+	p := new(Sample_Kind)
+	*p = x
+	return p
+}
+
+func (x Sample_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Sample_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_varopt_proto_enumTypes[0].Descriptor()
+}
+
+func (Sample_Kind) Type() protoreflect.EnumType {
+	return &file_varopt_proto_enumTypes[0]
+}
+
+func (x Sample_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Sample_Kind.Descriptor instead.
+func (Sample_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_varopt_proto_rawDescGZIP(), []int{1, 0}
+}
+
+type Sampler struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Capacity      int64                  `protobuf:"varint,1,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	Tau           float64                `protobuf:"fixed64,2,opt,name=tau,proto3" json:"tau,omitempty"`
+	TotalCount    int64                  `protobuf:"varint,3,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
+	TotalWeight   float64                `protobuf:"fixed64,4,opt,name=total_weight,json=totalWeight,proto3" json:"total_weight,omitempty"`
+	Samples       []*Sample              `protobuf:"bytes,5,rep,name=samples,proto3" json:"samples,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sampler) Reset() {
+	*x = Sampler{}
+	mi := &file_varopt_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sampler) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sampler) ProtoMessage() {}
+
+func (x *Sampler) ProtoReflect() protoreflect.Message {
+	mi := &file_varopt_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sampler.ProtoReflect.Descriptor instead.
+func (*Sampler) Descriptor() ([]byte, []int) {
+	return file_varopt_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Sampler) GetCapacity() int64 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+func (x *Sampler) GetTau() float64 {
+	if x != nil {
+		return x.Tau
+	}
+	return 0
+}
+
+func (x *Sampler) GetTotalCount() int64 {
+	if x != nil {
+		return x.TotalCount
+	}
+	return 0
+}
+
+func (x *Sampler) GetTotalWeight() float64 {
+	if x != nil {
+		return x.TotalWeight
+	}
+	return 0
+}
+
+func (x *Sampler) GetSamples() []*Sample {
+	if x != nil {
+		return x.Samples
+	}
+	return nil
+}
+
+type Sample struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Item           []byte                 `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	Weight         float64                `protobuf:"fixed64,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	AdjustedWeight float64                `protobuf:"fixed64,3,opt,name=adjusted_weight,json=adjustedWeight,proto3" json:"adjusted_weight,omitempty"`
+	Kind           Sample_Kind            `protobuf:"varint,4,opt,name=kind,proto3,enum=varopt.Sample_Kind" json:"kind,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Sample) Reset() {
+	*x = Sample{}
+	mi := &file_varopt_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sample) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sample) ProtoMessage() {}
+
+func (x *Sample) ProtoReflect() protoreflect.Message {
+	mi := &file_varopt_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sample.ProtoReflect.Descriptor instead.
+func (*Sample) Descriptor() ([]byte, []int) {
+	return file_varopt_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Sample) GetItem() []byte {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+func (x *Sample) GetWeight() float64 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *Sample) GetAdjustedWeight() float64 {
+	if x != nil {
+		return x.AdjustedWeight
+	}
+	return 0
+}
+
+func (x *Sample) GetKind() Sample_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return Sample_RESERVOIR
+}
+
+var File_varopt_proto protoreflect.FileDescriptor
+
+const file_varopt_proto_rawDesc = "" +
+	"\n" +
+	"\fvaropt.proto\x12\x06varopt\"\xa5\x01\n" +
+	"\aSampler\x12\x1a\n" +
+	"\bcapacity\x18\x01 \x01(\x03R\bcapacity\x12\x10\n" +
+	"\x03tau\x18\x02 \x01(\x01R\x03tau\x12\x1f\n" +
+	"\vtotal_count\x18\x03 \x01(\x03R\n" +
+	"totalCount\x12!\n" +
+	"\ftotal_weight\x18\x04 \x01(\x01R\vtotalWeight\x12(\n" +
+	"\asamples\x18\x05 \x03(\v2\x0e.varopt.SampleR\asamples\"\xbe\x01\n" +
+	"\x06Sample\x12\x12\n" +
+	"\x04item\x18\x01 \x01(\fR\x04item\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x01R\x06weight\x12'\n" +
+	"\x0fadjusted_weight\x18\x03 \x01(\x01R\x0eadjustedWeight\x12'\n" +
+	"\x04kind\x18\x04 \x01(\x0e2\x13.varopt.Sample.KindR\x04kind\"6\n" +
+	"\x04Kind\x12\r\n" +
+	"\tRESERVOIR\x10\x00\x12\t\n" +
+	"\x05FIRST\x10\x01\x12\b\n" +
+	"\x04LAST\x10\x02\x12\n" +
+	"\n" +
+	"\x06STICKY\x10\x03B&Z$github.com/lightstep/varopt/varoptpbb\x06proto3"
+
+var (
+	file_varopt_proto_rawDescOnce sync.Once
+	file_varopt_proto_rawDescData []byte
+)
+
+func file_varopt_proto_rawDescGZIP() []byte {
+	file_varopt_proto_rawDescOnce.Do(func() {
+		file_varopt_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_varopt_proto_rawDesc), len(file_varopt_proto_rawDesc)))
+	})
+	return file_varopt_proto_rawDescData
+}
+
+var file_varopt_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_varopt_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_varopt_proto_goTypes = []any{
+	(Sample_Kind)(0), // 0: varopt.Sample.Kind
+	(*Sampler)(nil),  // 1: varopt.Sampler
+	(*Sample)(nil),   // 2: varopt.Sample
+}
+var file_varopt_proto_depIdxs = []int32{
+	2, // 0: varopt.Sampler.samples:type_name -> varopt.Sample
+	0, // 1: varopt.Sample.kind:type_name -> varopt.Sample.Kind
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_varopt_proto_init() }
+func file_varopt_proto_init() {
+	if File_varopt_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_varopt_proto_rawDesc), len(file_varopt_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_varopt_proto_goTypes,
+		DependencyIndexes: file_varopt_proto_depIdxs,
+		EnumInfos:         file_varopt_proto_enumTypes,
+		MessageInfos:      file_varopt_proto_msgTypes,
+	}.Build()
+	File_varopt_proto = out.File
+	file_varopt_proto_goTypes = nil
+	file_varopt_proto_depIdxs = nil
+}
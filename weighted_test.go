@@ -76,6 +76,6 @@ func ExampleNew() {
 
 	// Output:
 	// Total sum error 2.4e-11%
-	// Color mean absolute percentage error 0.73%
-	// Protocol mean absolute percentage error 1.62%
+	// Color mean absolute percentage error 0.69%
+	// Protocol mean absolute percentage error 1.58%
 }